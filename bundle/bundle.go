@@ -0,0 +1,545 @@
+// Package bundle builds code context bundles: the selected files of a
+// repository, redacted, compressed and counted in tokens, ready to hand to a
+// language model. It never prints; problems with individual files are
+// reported in Bundle.Diagnostics.
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"codecopy/config"
+	"codecopy/constants"
+	"codecopy/helpers"
+	"codecopy/minify"
+	"codecopy/redact"
+	"codecopy/symbols"
+)
+
+// Options configures Build. The zero value, apart from Root, builds the
+// bundle the codecopy command copies by default.
+type Options struct {
+	// Root is the repository root; it defaults to the current directory.
+	Root string
+
+	// Files lists files and directories to include, relative to Root or
+	// absolute, and Globs adds the files whose path relative to Root matches
+	// one of the patterns, as in .gitignore. With neither, the source and
+	// build files of the projects detected under Root are included, less
+	// generated files.
+	Files []string
+	Globs []string
+	// Symbols, when Files and Globs are empty, selects the Go declarations
+	// named by each spec (pkg.Func, pkg.Type or pkg.Type.Method) and the
+	// module code they reference, including only those declarations.
+	Symbols []string
+	// Grep, when Files, Globs and Symbols are empty, selects the files whose
+	// contents match the pattern, a regular expression or a whole-word
+	// identifier. With GrepContext, only the lines within that many lines of
+	// a match are included.
+	Grep        string
+	GrepContext int
+	// Language restricts the default selection to the named language.
+	Language string
+
+	// Outline and Truncate list files, relative to Root or absolute, that
+	// are reduced to their declarations or to their head and tail.
+	Outline  []string
+	Truncate []string
+
+	// MaxBytes, MaxLines and MaxFileTokens truncate files over any of the
+	// limits to their head and tail; 0 means no limit.
+	MaxBytes      int
+	MaxLines      int
+	MaxFileTokens int
+	// MaxFileSize leaves out files larger than this many bytes that are not
+	// truncated, outlined, summarized or rendered as notebooks; 0 means the
+	// default limit.
+	MaxFileSize          int
+	DisableFileSizeLimit bool
+	// SummarizeOver replaces structured data files and lockfiles with more
+	// tokens than this with a summary; 0 means the default threshold.
+	SummarizeOver    int
+	DisableSummaries bool
+	// NotebookOutputLines keeps up to this many lines of each Jupyter
+	// notebook cell output.
+	NotebookOutputLines int
+	// BinaryPlaceholders lists binary files with their size and type
+	// instead of skipping them.
+	BinaryPlaceholders bool
+	// Minify strips comments and whitespace; nil leaves content as is.
+	Minify *minify.Options
+	// KeepHeaders keeps license headers shared by several files in every
+	// file instead of emitting them once.
+	KeepHeaders bool
+
+	// IncludeGenerated keeps generated files in the default selection, and
+	// OutlineGenerated keeps them as outlines.
+	IncludeGenerated bool
+	OutlineGenerated bool
+	// AllowSensitive includes files on the deny-list, such as private keys.
+	AllowSensitive bool
+	// Confine refuses paths that lead outside Root, following symlinks, and
+	// leaves out selected files that resolve outside it, for requests from
+	// callers that must not read the rest of the file system.
+	Confine bool
+	// DisableRedaction copies secrets verbatim.
+	DisableRedaction bool
+
+	// Budget fails the build with a *BudgetError when the bundle has more
+	// tokens; 0 means no budget.
+	Budget int
+	// Strict fails the build when any file has a problem, such as a file
+	// that could not be read, instead of leaving the file out.
+	Strict bool
+
+	// Config holds the user and project configuration; nil loads it from Root.
+	Config *config.Config
+
+	// Sinks receive the finished bundle, in order.
+	Sinks []Sink
+}
+
+// Bundle is a generated code context.
+type Bundle struct {
+	// Root is the absolute repository root.
+	Root string
+	// Text is the code context as the codecopy command copies it.
+	Text        string
+	TotalTokens int
+	// Files are the included files in order.
+	Files []File
+	// SharedHeaders are the leading comment blocks emitted once for
+	// several files.
+	SharedHeaders []Header
+	// Skipped are the selected files left out, and Denied the sensitive
+	// files refused, relative to Root.
+	Skipped []Skipped
+	Denied  []string
+	// Redactions holds the secrets redacted from each file, by path
+	// relative to Root.
+	Redactions map[string][]redact.Finding
+	// Diagnostics are the problems with files that did not stop the build,
+	// such as files that could not be read.
+	Diagnostics Diagnostics
+}
+
+// File is a file included in a bundle and the transformations applied to it.
+type File struct {
+	// Path is relative to the bundle root with forward slashes.
+	Path    string
+	AbsPath string
+	// Language is the name of the file's language, if known.
+	Language string
+	// Content is the file's content as it appears in the bundle.
+	Content string
+	Tokens  int
+
+	// Redacted is the number of secrets redacted.
+	Redacted   int
+	Summarized bool
+	Outlined   bool
+	// SharedHeader is the ID of the shared header removed from the file, or 0.
+	SharedHeader int
+	// MinifiedFrom is the token count before minification when it saved
+	// tokens, or 0.
+	MinifiedFrom int
+	// OmittedLines is the number of lines removed by truncation.
+	OmittedLines int
+
+	fenceTag string
+}
+
+// Header is a license header shared by several files.
+type Header struct {
+	ID   int
+	Text string
+	// Files are the paths, relative to the bundle root, the header was removed from.
+	Files []string
+}
+
+// Marker returns the text that stands for the header in the bundle.
+func (h Header) Marker() string {
+	return minify.Header{ID: h.ID}.Marker()
+}
+
+// Skipped is a selected file left out of a bundle.
+type Skipped struct {
+	Path    string
+	AbsPath string
+	Reason  string
+}
+
+// BudgetError reports a bundle with more tokens than the budget. Build
+// returns the bundle alongside it.
+type BudgetError struct {
+	Tokens int
+	Budget int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("bundle has %d tokens, over the budget of %d", e.Tokens, e.Budget)
+}
+
+// PathError reports a path given in Options that does not exist or, in a
+// confined build, leads outside the root.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ErrOutsideRoot is the PathError cause of a path outside a confined root.
+var ErrOutsideRoot = errors.New("outside the repository")
+
+// Selection is the files a build includes and the settings they are
+// generated with. Callers may change both before generating, for example
+// to let a user uncheck or downgrade files.
+type Selection struct {
+	// Root is the absolute repository root.
+	Root string
+	// Files are the absolute paths of the selected files in order.
+	Files    []string
+	Settings Settings
+	// Skipped are the files left out while selecting, such as generated
+	// files, and Denied the sensitive files refused, relative to Root.
+	Skipped []Skipped
+	Denied  []string
+}
+
+// Build selects files under opts.Root, generates their bundle and writes it
+// to the sinks.
+func Build(ctx context.Context, opts Options) (*Bundle, error) {
+	selection, err := Select(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	b, err := selection.Generate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Strict && len(b.Diagnostics) > 0 {
+		return b, b.Diagnostics.Err()
+	}
+	if opts.Budget > 0 && b.TotalTokens > opts.Budget {
+		return b, &BudgetError{Tokens: b.TotalTokens, Budget: opts.Budget}
+	}
+
+	for _, sink := range opts.Sinks {
+		if err := sink.Write(ctx, b); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// Select chooses the files Build would include and the settings it would
+// generate them with, without reading them.
+func Select(ctx context.Context, opts Options) (*Selection, error) {
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := opts.Config
+	if cfg == nil {
+		if cfg, err = config.Load(root); err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %v", err)
+		}
+	}
+
+	settings, err := opts.Settings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Selection{Root: root, Settings: settings}
+
+	files, err := s.selectFiles(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Confine {
+		var confined []string
+		for _, file := range files {
+			if !resolvesWithin(root, file) {
+				s.Skipped = append(s.Skipped, Skipped{Path: helpers.RelativePath(root, file), AbsPath: file, Reason: ErrOutsideRoot.Error()})
+				continue
+			}
+			confined = append(confined, file)
+		}
+		files = confined
+	}
+
+	if !opts.AllowSensitive {
+		var denied []string
+		files, denied = helpers.NewDenyList(cfg.Deny).Filter(root, files)
+		for _, file := range denied {
+			s.Denied = append(s.Denied, helpers.RelativePath(root, file))
+		}
+	}
+	s.Files = files
+
+	for _, downgrade := range []struct {
+		paths []string
+		modes map[string]bool
+	}{
+		{opts.Outline, s.Settings.Outlines},
+		{opts.Truncate, s.Settings.Truncated},
+	} {
+		resolved, err := opts.expand(root, downgrade.paths)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range resolved {
+			downgrade.modes[file] = true
+		}
+	}
+	return s, nil
+}
+
+// Generate reads the selected files and generates their bundle.
+func (s *Selection) Generate(ctx context.Context) (*Bundle, error) {
+	b, err := Generate(ctx, s.Root, s.Files, s.Settings)
+	if err != nil {
+		return nil, err
+	}
+	b.Skipped = append(append([]Skipped{}, s.Skipped...), b.Skipped...)
+	b.Denied = s.Denied
+	return b, nil
+}
+
+// Settings converts the content options into generation settings.
+func (opts Options) Settings(cfg *config.Config) (Settings, error) {
+	settings := Settings{
+		Snippets:           make(map[string]string),
+		Outlines:           make(map[string]bool),
+		Truncated:          make(map[string]bool),
+		BinaryPlaceholders: opts.BinaryPlaceholders,
+		Limits: helpers.TruncateLimits{
+			MaxBytes:  opts.MaxBytes,
+			MaxLines:  opts.MaxLines,
+			MaxTokens: opts.MaxFileTokens,
+		},
+		MaxFileSize:   int64(opts.MaxFileSize),
+		SummarizeOver: opts.SummarizeOver,
+		Notebook:      helpers.NotebookOptions{OutputLines: opts.NotebookOutputLines},
+		Minify:        opts.Minify,
+		DedupeHeaders: !opts.KeepHeaders,
+		Registry:      cfg.Registry(),
+	}
+	if settings.SummarizeOver == 0 {
+		settings.SummarizeOver = constants.SummarizeThreshold
+	}
+	if opts.DisableSummaries {
+		settings.SummarizeOver = 0
+	}
+	if settings.MaxFileSize == 0 {
+		settings.MaxFileSize = constants.MaxFileBytes
+	}
+	if opts.DisableFileSizeLimit {
+		settings.MaxFileSize = 0
+	}
+	if !opts.DisableRedaction {
+		redactor, err := redact.New(cfg.Redact.Rules)
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.Redactor = redactor
+	}
+	return settings, nil
+}
+
+// selectFiles returns the files given in opts, the files declaring the
+// requested symbols or matching the grep pattern, or the default selection.
+// Generated files are skipped or outlined unless files are given or symbols
+// requested; grep match windows, symbol snippets and generated outlines are
+// recorded in the settings.
+func (s *Selection) selectFiles(opts Options) ([]string, error) {
+	root, settings := s.Root, s.Settings
+
+	switch {
+	case len(opts.Files) > 0 || len(opts.Globs) > 0:
+		files, err := opts.expand(root, opts.Files)
+		if err != nil {
+			return nil, err
+		}
+		if len(opts.Globs) > 0 {
+			all, err := helpers.ListFiles(root)
+			if err != nil {
+				return nil, err
+			}
+			globs := helpers.CompileGlobs(opts.Globs)
+			seen := make(map[string]bool, len(files))
+			for _, file := range files {
+				seen[file] = true
+			}
+			for _, file := range all {
+				if !seen[file] && matchesAny(globs, helpers.RelativePath(root, file)) {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+		return files, nil
+
+	case len(opts.Symbols) > 0:
+		snippets, files, err := symbols.Extract(root, opts.Symbols)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract symbols: %v", err)
+		}
+		for file, snippet := range snippets {
+			settings.Snippets[file] = snippet
+		}
+		return files, nil
+	}
+
+	var files []string
+	if opts.Grep != "" {
+		re, err := helpers.CompileGrepPattern(opts.Grep)
+		if err != nil {
+			return nil, err
+		}
+		if files, err = helpers.GrepFiles(root, re); err != nil {
+			return nil, fmt.Errorf("failed to search files: %v", err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files match %q", opts.Grep)
+		}
+		if opts.GrepContext > 0 {
+			for _, file := range files {
+				content, err := helpers.ReadFileContent(file, settings.MaxFileSize)
+				if err != nil {
+					continue
+				}
+				if windows := helpers.MatchWindows(content, re, opts.GrepContext); windows != "" {
+					settings.Snippets[file] = windows
+				}
+			}
+		}
+	} else {
+		registry := settings.Registry
+		language := registry.ByName(opts.Language)
+		if opts.Language != "" && language == nil {
+			return nil, fmt.Errorf("unknown language %q", opts.Language)
+		}
+		projects, err := helpers.DetectProjects(root, registry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect projects: %v", err)
+		}
+		if files, err = helpers.GetRelevantFiles(root, registry, projects, language); err != nil {
+			return nil, fmt.Errorf("failed to get relevant files: %v", err)
+		}
+		if len(files) == 0 {
+			if files, err = helpers.ListFiles(root); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if opts.IncludeGenerated {
+		return files, nil
+	}
+
+	classifier := helpers.NewGeneratedClassifier(root)
+	var handWritten []string
+	for _, file := range files {
+		if generated, reason := classifier.Classify(file); generated {
+			if !opts.OutlineGenerated {
+				s.Skipped = append(s.Skipped, Skipped{Path: helpers.RelativePath(root, file), AbsPath: file, Reason: "generated: " + reason})
+				continue
+			}
+			settings.Outlines[file] = true
+		}
+		handWritten = append(handWritten, file)
+	}
+	return handWritten, nil
+}
+
+// expand resolves files and directories given in opts into files, refusing
+// paths that do not exist and, in a confined build, paths outside root.
+func (opts Options) expand(root string, paths []string) ([]string, error) {
+	for _, path := range paths {
+		file := path
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(root, file)
+		}
+		if opts.Confine && !helpers.IsWithin(root, file) {
+			return nil, &PathError{Path: path, Err: ErrOutsideRoot}
+		}
+		if _, err := os.Stat(file); err != nil {
+			return nil, &PathError{Path: path, Err: err}
+		}
+		if opts.Confine && !resolvesWithin(root, file) {
+			return nil, &PathError{Path: path, Err: ErrOutsideRoot}
+		}
+	}
+	return helpers.ExpandPaths(root, paths)
+}
+
+// resolvesWithin reports whether path still lies within root once symlinks
+// are followed.
+func resolvesWithin(root, path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolvedRoot
+	}
+	return helpers.IsWithin(root, resolved)
+}
+
+// Largest returns the bundle's files with the most tokens first.
+func (b *Bundle) Largest() []File {
+	files := append([]File{}, b.Files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Tokens > files[j].Tokens
+	})
+	return files
+}
+
+// Excluded lists the directories under root, relative to it, whose files
+// codecopy never includes because they are ignored, such as node_modules.
+func Excluded(root string) ([]string, error) {
+	var excluded []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != root && helpers.IsIgnoredDir(info.Name()) {
+			excluded = append(excluded, helpers.RelativePath(root, path))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return excluded, fmt.Errorf("failed to get excluded directories: %v", err)
+	}
+	return excluded, nil
+}
+
+// matchesAny reports whether relPath matches one of the glob patterns.
+func matchesAny(globs []helpers.Glob, relPath string) bool {
+	for _, glob := range globs {
+		if glob.Match(relPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codecopy/helpers"
+)
+
+func TestExcluded(t *testing.T) {
+	// The repository itself sits below a directory named like an ignored
+	// one, which must not exclude its files.
+	root := filepath.Join(t.TempDir(), "build", "repo")
+	for _, name := range []string{
+		"catalog.go",
+		"helpers/binary.go",
+		"logger/log.go",
+		"bin/tool",
+		"node_modules/left-pad/index.js",
+		"web/node_modules/react/index.js",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	excluded, err := Excluded(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bin", "node_modules", "web/node_modules"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("Excluded = %v, want %v", excluded, want)
+	}
+}
+
+func TestGenerateMaxFileSize(t *testing.T) {
+	root := t.TempDir()
+	var log, data strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&log, "line %d of the log\n", i)
+	}
+	data.WriteString("[")
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			data.WriteString(",")
+		}
+		fmt.Fprintf(&data, `{"id": %d, "name": "item %d"}`, i, i)
+	}
+	data.WriteString("]\n")
+	files := map[string]string{"app.log": log.String(), "data.json": data.String()}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		settings Settings
+		// included is false when the file is left out as too large.
+		included bool
+	}{
+		{"over the limit", "app.log", Settings{MaxFileSize: 1000}, false},
+		{"no limit", "app.log", Settings{}, true},
+		{"truncated by limits", "app.log", Settings{MaxFileSize: 1000, Limits: helpers.TruncateLimits{MaxLines: 10}}, true},
+		{"downgraded to truncated", "app.log", Settings{MaxFileSize: 1000, Truncated: map[string]bool{filepath.Join(root, "app.log"): true}}, true},
+		{"summarized", "data.json", Settings{MaxFileSize: 1000, SummarizeOver: 10}, true},
+		{"not summarized", "data.json", Settings{MaxFileSize: 1000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Generate(context.Background(), root, []string{filepath.Join(root, tt.file)}, tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if included := len(b.Files) == 1; included != tt.included {
+				t.Fatalf("included = %v, want %v (diagnostics %v)", included, tt.included, b.Diagnostics)
+			}
+			if !tt.included && (len(b.Diagnostics) != 1 || b.Diagnostics[0].Kind != TooLarge) {
+				t.Errorf("diagnostics = %v, want one too_large", b.Diagnostics)
+			}
+			if tt.included && len(b.Files[0].Content) >= len(files[tt.file]) && tt.settings.MaxFileSize > 0 {
+				t.Errorf("content has %d bytes, want it reduced from %d", len(b.Files[0].Content), len(files[tt.file]))
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"codecopy/helpers"
+)
+
+// Kind classifies a problem with a file.
+type Kind string
+
+// Kinds of file problems.
+const (
+	// PermissionDenied: the file could not be opened.
+	PermissionDenied Kind = "permission_denied"
+	// NotFound: the file disappeared or is a dangling symlink.
+	NotFound Kind = "not_found"
+	// InvalidUTF8: the file looks like text but is not valid UTF-8.
+	InvalidUTF8 Kind = "invalid_utf8"
+	// TooLarge: the file is over the size limit and was not read.
+	TooLarge Kind = "too_large"
+	// TokenizerFailure: the file's tokens could not be counted.
+	TokenizerFailure Kind = "tokenizer_failure"
+	// ReadFailure: any other error reading the file.
+	ReadFailure Kind = "read_failure"
+)
+
+// FileError is a problem with one selected file. A file that could not be
+// read or counted is left out of the bundle; one that could not be truncated
+// is kept in full.
+type FileError struct {
+	// Path is relative to the bundle root with forward slashes.
+	Path    string
+	AbsPath string
+	Kind    Kind
+	Err     error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostics collects the file problems that did not stop a build. It is
+// also the error a strict build fails with.
+type Diagnostics []*FileError
+
+func (d Diagnostics) Error() string {
+	messages := make([]string, len(d))
+	for i, problem := range d {
+		messages[i] = problem.Error()
+	}
+	return fmt.Sprintf("%d file problem(s): %s", len(d), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the problems for errors.Is and errors.As.
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, len(d))
+	for i, problem := range d {
+		errs[i] = problem
+	}
+	return errs
+}
+
+// Err returns the problems as an error, or nil if there are none.
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
+
+// add records a problem with file.
+func (d *Diagnostics) add(root, file string, kind Kind, err error) {
+	*d = append(*d, &FileError{Path: helpers.RelativePath(root, file), AbsPath: file, Kind: kind, Err: err})
+}
+
+// addReadError records why file could not be read, classifying the error
+// returned by helpers.ReadFileContent.
+func (d *Diagnostics) addReadError(root, file string, err error) {
+	var tooLarge *helpers.FileTooLargeError
+	var binaryErr *helpers.BinaryFileError
+	cause := err
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		cause = pathErr.Err
+	}
+
+	switch {
+	case errors.As(err, &tooLarge):
+		d.add(root, file, TooLarge, fmt.Errorf("%s, over the %s limit", helpers.FormatSize(tooLarge.Size), helpers.FormatSize(tooLarge.Limit)))
+	case errors.As(err, &binaryErr):
+		d.add(root, file, InvalidUTF8, errors.New(binaryErr.Reason))
+	case errors.Is(err, fs.ErrPermission):
+		d.add(root, file, PermissionDenied, cause)
+	case errors.Is(err, fs.ErrNotExist):
+		d.add(root, file, NotFound, cause)
+	default:
+		d.add(root, file, ReadFailure, cause)
+	}
+}
@@ -0,0 +1,253 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"codecopy/constants"
+	"codecopy/helpers"
+	"codecopy/languages"
+	"codecopy/minify"
+	"codecopy/redact"
+	"codecopy/summarize"
+)
+
+// Settings control how Generate turns files into a bundle. Maps are keyed
+// by file path as passed to Generate.
+type Settings struct {
+	// Snippets replaces the full content of a file with pre-extracted text,
+	// such as grep match windows or extracted Go symbols.
+	Snippets map[string]string
+	// Outlines lists files reduced to their declarations, and Truncated
+	// files reduced to their first and last lines.
+	Outlines  map[string]bool
+	Truncated map[string]bool
+	// BinaryPlaceholders lists binary files with their size and type instead
+	// of leaving them out entirely.
+	BinaryPlaceholders bool
+	// Limits truncates oversized files to their head and tail.
+	Limits helpers.TruncateLimits
+	// MaxFileSize leaves out files larger than this many bytes, unless they
+	// are reduced by truncation, outlining, summarization or notebook
+	// rendering and so read in full; 0 means no limit.
+	MaxFileSize int64
+	// SummarizeOver replaces structured data files and lockfiles with more
+	// tokens than this with a summary; 0 disables summarization.
+	SummarizeOver int
+	// Notebook controls how Jupyter notebooks are rendered.
+	Notebook helpers.NotebookOptions
+	// Redactor replaces secrets before content is counted; nil disables
+	// redaction.
+	Redactor *redact.Redactor
+	// Minify strips comments and whitespace according to each file's
+	// language; nil leaves content as is.
+	Minify *minify.Options
+	// DedupeHeaders emits license headers shared by several files once
+	// instead of in every file.
+	DedupeHeaders bool
+	// Registry identifies each file's language.
+	Registry *languages.Registry
+}
+
+// Generate reads the files, applies the settings and counts the tokens of
+// the resulting bundle. Files that cannot be read or counted are left out and
+// recorded in Bundle.Diagnostics.
+func Generate(ctx context.Context, root string, files []string, settings Settings) (*Bundle, error) {
+	b := &Bundle{
+		Root:       root,
+		Redactions: make(map[string][]redact.Finding),
+	}
+	entries := make(map[string]*File)
+
+	// The first pass reads each file and applies the transformations that
+	// depend on the file alone.
+	var readFiles []string
+	contents := make(map[string]string)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entry := &File{Path: helpers.RelativePath(root, file), AbsPath: file}
+		if language := settings.languageOf(file); language != nil {
+			entry.Language, entry.fenceTag = language.Name, language.FenceTag
+		}
+
+		content, err := settings.read(file)
+		if err != nil {
+			var binaryErr *helpers.BinaryFileError
+			if !errors.As(err, &binaryErr) || binaryErr.Reason == helpers.InvalidUTF8 {
+				b.Diagnostics.addReadError(root, file, err)
+				continue
+			}
+			if !settings.BinaryPlaceholders {
+				b.Skipped = append(b.Skipped, Skipped{Path: entry.Path, AbsPath: file, Reason: binaryErr.Reason})
+				continue
+			}
+			content = binaryErr.Placeholder()
+		}
+
+		if settings.Redactor != nil {
+			var findings []redact.Finding
+			content, findings = settings.Redactor.Redact(content)
+			if len(findings) > 0 {
+				b.Redactions[entry.Path] = findings
+				entry.Redacted = len(findings)
+			}
+		}
+
+		if _, isSnippet := settings.Snippets[file]; settings.SummarizeOver > 0 && !isSnippet {
+			if tokens, err := helpers.CountTokens(content); err == nil && tokens > settings.SummarizeOver {
+				if summary, ok := summarize.Summarize(file, content); ok {
+					content = summary
+					entry.Summarized = true
+				}
+			}
+		}
+
+		if settings.Outlines[file] {
+			content = helpers.Outline(file, content)
+			entry.Outlined = true
+		}
+
+		readFiles = append(readFiles, file)
+		contents[file] = content
+		entries[file] = entry
+	}
+
+	// Leading comment blocks repeated across files are emitted once.
+	if settings.DedupeHeaders {
+		for _, header := range minify.DeduplicateHeaders(readFiles, contents, settings.commentsFor) {
+			shared := Header{ID: header.ID, Text: header.Text}
+			for _, file := range header.Files {
+				entries[file].SharedHeader = header.ID
+				shared.Files = append(shared.Files, helpers.RelativePath(root, file))
+			}
+			b.SharedHeaders = append(b.SharedHeaders, shared)
+		}
+	}
+
+	var codeContext strings.Builder
+	for _, file := range readFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		content, entry := contents[file], entries[file]
+
+		if settings.Minify != nil {
+			before, err := helpers.CountTokens(content)
+			if err != nil {
+				b.Diagnostics.add(root, file, TokenizerFailure, err)
+				continue
+			}
+			opts := *settings.Minify
+			language := settings.languageOf(file)
+			if language == nil || language.SignificantIndent {
+				opts.NormalizeIndent = false
+			}
+			content = minify.Minify(content, settings.commentsFor(file), opts)
+			if after, err := helpers.CountTokens(content); err == nil && after < before {
+				entry.MinifiedFrom = before
+			}
+		}
+
+		limits := settings.Limits
+		if settings.Truncated[file] && (limits.MaxLines == 0 || limits.MaxLines > constants.DowngradeTruncateLines) {
+			limits.MaxLines = constants.DowngradeTruncateLines
+		}
+		if limits.IsSet() {
+			truncated, omitted, err := helpers.TruncateContent(content, limits)
+			if err != nil {
+				b.Diagnostics.add(root, file, TokenizerFailure, fmt.Errorf("failed to truncate: %v", err))
+			} else if omitted > 0 {
+				content = truncated
+				entry.OmittedLines = omitted
+			}
+		}
+
+		tokenCount, err := helpers.CountTokens(content)
+		if err != nil {
+			b.Diagnostics.add(root, file, TokenizerFailure, err)
+			continue
+		}
+
+		entry.Content = content
+		entry.Tokens = tokenCount
+		b.TotalTokens += tokenCount
+		b.Files = append(b.Files, *entry)
+
+		relPath := strings.TrimPrefix(file, root+"/")
+		codeContext.WriteString(fmt.Sprintf("\n%s\n\n", relPath))
+		codeContext.WriteString(content)
+		codeContext.WriteString("\n")
+	}
+
+	var sharedHeaders strings.Builder
+	for _, header := range b.SharedHeaders {
+		sharedHeaders.WriteString(fmt.Sprintf("\n%s\n\n%s\n", header.Marker(), header.Text))
+	}
+	if sharedHeaders.Len() > 0 {
+		headerTokens, err := helpers.CountTokens(sharedHeaders.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to count tokens for shared headers: %v", err)
+		}
+		b.TotalTokens += headerTokens
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Root Directory: %s\n\n", root))
+	output.WriteString(fmt.Sprintf("Total Tokens: %d\n\n", b.TotalTokens))
+	if sharedHeaders.Len() > 0 {
+		output.WriteString("Shared Headers:\n")
+		output.WriteString(sharedHeaders.String())
+		output.WriteString("\n")
+	}
+	output.WriteString("Code Context:\n")
+	output.WriteString(codeContext.String())
+	b.Text = output.String()
+
+	return b, nil
+}
+
+// read returns the file's snippet, or its content with notebooks rendered
+// as cells.
+func (s Settings) read(file string) (string, error) {
+	if snippet, ok := s.Snippets[file]; ok {
+		return snippet, nil
+	}
+	limit := s.MaxFileSize
+	if s.reduces(file) {
+		limit = 0
+	}
+	content, err := helpers.ReadFileContent(file, limit)
+	if err != nil || !helpers.IsNotebook(file) {
+		return content, err
+	}
+	return helpers.RenderNotebook(content, s.Notebook)
+}
+
+// reduces reports whether the file's content is cut down after it is read,
+// so that its size on disk says little about its size in the bundle.
+func (s Settings) reduces(file string) bool {
+	return s.Limits.IsSet() || s.Truncated[file] || s.Outlines[file] || helpers.IsNotebook(file) ||
+		(s.SummarizeOver > 0 && summarize.Supports(file))
+}
+
+// languageOf returns the file's language, or nil if it is unknown.
+func (s Settings) languageOf(file string) *languages.Language {
+	if s.Registry == nil {
+		return nil
+	}
+	return s.Registry.ByFile(file)
+}
+
+// commentsFor returns the comment syntax of the file's language, or none if
+// the language is unknown.
+func (s Settings) commentsFor(file string) languages.Comments {
+	if language := s.languageOf(file); language != nil {
+		return language.Comments
+	}
+	return languages.Comments{}
+}
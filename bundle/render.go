@@ -0,0 +1,194 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"codecopy/helpers"
+)
+
+// Format is a textual form of a bundle.
+type Format string
+
+// Formats a bundle can be rendered in.
+const (
+	// FormatText is the plain text the codecopy command copies.
+	FormatText Format = "text"
+	// FormatMarkdown fences each file with its language's fence tag.
+	FormatMarkdown Format = "markdown"
+	// FormatJSON lists the files, their contents and token counts as JSON.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format named s; the empty string is FormatText.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatMarkdown, FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q: use text, markdown or json", s)
+}
+
+// bundleJSON is the JSON form of a bundle.
+type bundleJSON struct {
+	Root          string         `json:"root"`
+	TotalTokens   int            `json:"total_tokens"`
+	Files         []fileJSON     `json:"files"`
+	SharedHeaders []headerJSON   `json:"shared_headers,omitempty"`
+	Skipped       []skipJSON     `json:"skipped,omitempty"`
+	Redacted      map[string]int `json:"redacted,omitempty"`
+	Diagnostics   []problemJSON  `json:"diagnostics,omitempty"`
+}
+
+// fileJSON is an included file in the JSON form of a bundle.
+type fileJSON struct {
+	Path     string `json:"path"`
+	Language string `json:"language,omitempty"`
+	Tokens   int    `json:"tokens"`
+	Notes    string `json:"notes,omitempty"`
+	Content  string `json:"content"`
+}
+
+// headerJSON is a shared leading comment block in the JSON form of a bundle.
+type headerJSON struct {
+	Marker string   `json:"marker"`
+	Text   string   `json:"text"`
+	Files  []string `json:"files"`
+}
+
+// skipJSON is a file left out of the bundle and why.
+type skipJSON struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// problemJSON is a file problem in the JSON form of a bundle.
+type problemJSON struct {
+	Path    string `json:"path"`
+	Kind    Kind   `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Render formats the bundle as plain text, Markdown with a fenced block per
+// file, or JSON.
+func (b *Bundle) Render(format Format) (string, error) {
+	switch format {
+	case "", FormatText:
+		return b.Text, nil
+	case FormatMarkdown:
+		return b.markdown(), nil
+	case FormatJSON:
+		data, err := json.MarshalIndent(b.json(), "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("unknown format %q: use text, markdown or json", format)
+}
+
+// Notes describes the transformations applied to the file, as shown next to
+// its token count in the codecopy tree.
+func (f File) Notes() string {
+	var notes []string
+	if f.Redacted > 0 {
+		notes = append(notes, fmt.Sprintf("🔒 %d redacted", f.Redacted))
+	}
+	if f.Summarized {
+		notes = append(notes, "📋 summarized")
+	}
+	if f.Outlined {
+		notes = append(notes, "📑 outline")
+	}
+	if f.SharedHeader > 0 {
+		notes = append(notes, fmt.Sprintf("📎 header %d", f.SharedHeader))
+	}
+	if f.MinifiedFrom > 0 {
+		notes = append(notes, fmt.Sprintf("🗜️  %d → %d", f.MinifiedFrom, f.Tokens))
+	}
+	if f.OmittedLines > 0 {
+		notes = append(notes, fmt.Sprintf("✂️  truncated (%s omitted)", helpers.FormatLines(f.OmittedLines)))
+	}
+	return strings.Join(notes, "  ")
+}
+
+// markdown formats the bundle as Markdown, fencing each file with its
+// language's fence tag.
+func (b *Bundle) markdown() string {
+	var out strings.Builder
+	out.WriteString("# Code Context\n\n")
+	out.WriteString(fmt.Sprintf("Root directory: `%s` · %d tokens\n", b.Root, b.TotalTokens))
+
+	if len(b.SharedHeaders) > 0 {
+		out.WriteString("\n## Shared Headers\n")
+		for _, header := range b.SharedHeaders {
+			out.WriteString(fmt.Sprintf("\n### %s\n\n", header.Marker()))
+			writeFenced(&out, header.Text, "")
+		}
+	}
+
+	for _, file := range b.Files {
+		out.WriteString(fmt.Sprintf("\n## %s\n\n", file.Path))
+		writeFenced(&out, file.Content, file.fenceTag)
+	}
+	return out.String()
+}
+
+// writeFenced writes content as a fenced code block, using a fence longer
+// than any run of backticks in the content.
+func writeFenced(out *strings.Builder, content, tag string) {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+
+	out.WriteString(fence + tag + "\n")
+	out.WriteString(content)
+	if !strings.HasSuffix(content, "\n") {
+		out.WriteString("\n")
+	}
+	out.WriteString(fence + "\n")
+}
+
+// json converts the bundle into its JSON form.
+func (b *Bundle) json() bundleJSON {
+	out := bundleJSON{
+		Root:        b.Root,
+		TotalTokens: b.TotalTokens,
+		Files:       []fileJSON{},
+	}
+	for _, file := range b.Files {
+		out.Files = append(out.Files, fileJSON{
+			Path:     file.Path,
+			Language: file.Language,
+			Tokens:   file.Tokens,
+			Notes:    file.Notes(),
+			Content:  file.Content,
+		})
+	}
+	for _, header := range b.SharedHeaders {
+		out.SharedHeaders = append(out.SharedHeaders, headerJSON{Marker: header.Marker(), Text: header.Text, Files: header.Files})
+	}
+	for _, skipped := range b.Skipped {
+		out.Skipped = append(out.Skipped, skipJSON{Path: skipped.Path, Reason: skipped.Reason})
+	}
+	if len(b.Redactions) > 0 {
+		out.Redacted = make(map[string]int)
+		for file, findings := range b.Redactions {
+			out.Redacted[file] = len(findings)
+		}
+	}
+	for _, problem := range b.Diagnostics {
+		out.Diagnostics = append(out.Diagnostics, problemJSON{Path: problem.Path, Kind: problem.Kind, Message: problem.Err.Error()})
+	}
+	return out
+}
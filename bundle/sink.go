@@ -0,0 +1,66 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"codecopy/helpers"
+)
+
+// Sink receives a finished bundle, such as a file or the clipboard.
+type Sink interface {
+	Write(ctx context.Context, b *Bundle) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, b *Bundle) error
+
+// Write calls f(ctx, b).
+func (f SinkFunc) Write(ctx context.Context, b *Bundle) error {
+	return f(ctx, b)
+}
+
+// WriterSink writes the bundle to W in Format.
+type WriterSink struct {
+	W      io.Writer
+	Format Format
+}
+
+func (s WriterSink) Write(ctx context.Context, b *Bundle) error {
+	text, err := b.Render(s.Format)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(s.W, text); err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	return nil
+}
+
+// FileSink writes the bundle to the file at Path in Format, replacing it.
+type FileSink struct {
+	Path   string
+	Format Format
+}
+
+func (s FileSink) Write(ctx context.Context, b *Bundle) error {
+	text, err := b.Render(s.Format)
+	if err != nil {
+		return err
+	}
+	return helpers.WriteToFile(text, s.Path)
+}
+
+// ClipboardSink copies the bundle to the system clipboard in Format.
+type ClipboardSink struct {
+	Format Format
+}
+
+func (s ClipboardSink) Write(ctx context.Context, b *Bundle) error {
+	text, err := b.Render(s.Format)
+	if err != nil {
+		return err
+	}
+	return helpers.CopyToClipboard(text)
+}
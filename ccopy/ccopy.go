@@ -1,18 +1,23 @@
 package ccopy
 
 import (
+	"context"
 	"fmt"
 	"github.com/fatih/color"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"codecopy/bundle"
+	"codecopy/config"
 	"codecopy/constants"
 	"codecopy/helpers"
+	"codecopy/languages"
+	"codecopy/minify"
+	"codecopy/picker"
+	"codecopy/selections"
 	"codecopy/ui"
 )
 
-// Run is the main entry point for the codecopy command.
 // Run is the main entry point for the codecopy command.
 func Run(args []string) error {
 	rootDir, err := os.Getwd()
@@ -20,152 +25,366 @@ func Run(args []string) error {
 		return fmt.Errorf("failed to get current directory: %v", err)
 	}
 
-	projectType, err := helpers.DetectProjectType(rootDir)
+	cfg, err := config.Load(rootDir)
 	if err != nil {
-		return fmt.Errorf("failed to detect project type: %v", err)
+		return fmt.Errorf("failed to load configuration: %v", err)
 	}
+	registry := cfg.Registry()
 
-	manualMode := helpers.ContainsFlag(args, "-m")
-	languageFlags := []string{"-py", "-rs", "-go", "-js", "-php", "-java", "-rb", "-cs"}
-	selectedLanguage := helpers.GetSelectedLanguage(args, languageFlags)
+	projects, err := helpers.DetectProjects(rootDir, registry)
+	if err != nil {
+		return fmt.Errorf("failed to detect projects: %v", err)
+	}
 
-	var selectedFiles []string
-	if manualMode {
-		selectedFiles, err = helpers.SelectFiles(rootDir)
-		if err != nil {
-			return fmt.Errorf("failed to perform manual file selection: %v", err)
-		}
-	} else {
-		selectedFiles, err = helpers.GetRelevantFiles(rootDir, projectType, selectedLanguage)
-		if err != nil {
-			return fmt.Errorf("failed to get relevant files: %v", err)
-		}
+	store, setName, recalled, args, err := openSelections(rootDir, args)
+	if err != nil {
+		return err
 	}
 
-	if len(selectedFiles) == 0 {
-		treeOutput, err := helpers.GenerateTree(rootDir)
-		if err != nil {
-			return fmt.Errorf("failed to generate tree: %v", err)
-		}
-		selectedFiles = helpers.ExtractFilesFromTree(treeOutput)
+	nonInteractive := isNonInteractive(args)
+	if nonInteractive && helpers.ContainsFlag(args, "-m") {
+		return fmt.Errorf("-m needs an interactive terminal: select files with --files or --stdin instead")
+	}
+	policy, err := overBudgetPolicy(args, nonInteractive)
+	if err != nil {
+		return err
 	}
 
-	codeContext, totalTokens, fileTokenCounts, err := generateCodeContext(rootDir, selectedFiles)
+	opts, err := newOptions(rootDir, args, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	selection, err := selectFiles(ctx, opts, args, recalled)
+	if err != nil {
+		return err
+	}
+	ui.DisplayDeniedFiles(selection.Denied)
+
+	b, err := selection.Generate(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to generate code context: %v", err)
 	}
 
-	if totalTokens > constants.TokenLimit {
-		ui.DisplayTokenWarning(totalTokens)
-		selectedFiles, err = helpers.SelectFilesToRemove(selectedFiles)
-		if err != nil {
-			return fmt.Errorf("failed to select files to remove: %v", err)
-		}
-		codeContext, totalTokens, fileTokenCounts, err = generateCodeContext(rootDir, selectedFiles)
-		if err != nil {
-			return fmt.Errorf("failed to generate code context: %v", err)
+	if b.TotalTokens > constants.TokenLimit {
+		ui.DisplayTokenWarning(b.TotalTokens)
+		switch policy {
+		case policyFail:
+			return &ExitError{Code: constants.ExitOverBudget, Err: fmt.Errorf("the code context has %d tokens, over the limit of %d", b.TotalTokens, constants.TokenLimit)}
+		case policyFit:
+			if b, err = fitToBudget(ctx, selection, b, constants.TokenLimit); err != nil {
+				return err
+			}
+		default:
+			if b, err = trimToBudget(ctx, selection, b); err != nil {
+				return fmt.Errorf("failed to trim the selection: %v", err)
+			}
 		}
 	}
 
-	ui.DisplayProjectInfo(projectType, selectedFiles, fileTokenCounts)
+	if err := saveSelection(store, setName, selection, b, args); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	files, counts, notes := tokenCounts(b)
+	ui.DisplayProjectInfo(helpers.DescribeProjects(rootDir, projects), files, counts)
+	ui.DisplaySkippedFiles(b.Skipped)
 
-	excludedFiles := getExcludedFiles(rootDir)
-	if len(excludedFiles) > 0 {
-		color.New(color.FgYellow).Printf("🚫 Excluded files: %s\n\n", strings.Join(excludedFiles, ", "))
+	excludedDirs, err := bundle.Excluded(rootDir)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if len(excludedDirs) > 0 {
+		color.New(color.FgYellow).Printf("🚫 Excluded directories: %s\n\n", strings.Join(excludedDirs, ", "))
 	}
 
-	treeWithTokenCounts := helpers.BuildTreeWithTokenCounts(rootDir, selectedFiles, fileTokenCounts)
+	ui.DisplayRedactions(b.Redactions)
+
+	treeWithTokenCounts := helpers.BuildTreeWithTokenCounts(rootDir, files, counts, notes)
 	ui.DisplayTreeWithTokenCounts(treeWithTokenCounts)
-	ui.DisplayTotalTokens(totalTokens)
+	ui.DisplayTotalTokens(b.TotalTokens)
+	ui.DisplayDiagnostics(b.Diagnostics)
 
-	if err := helpers.CopyToClipboard(codeContext); err != nil {
-		ui.DisplayError(fmt.Errorf("failed to copy code context to clipboard: %v", err))
-		if err := helpers.WriteToFile(codeContext, "code_context.txt"); err != nil {
-			return fmt.Errorf("failed to write code context to file: %v", err)
-		}
-		ui.DisplaySuccess("Code context generated and written to code_context.txt")
-		return nil
+	if len(b.Diagnostics) > 0 && helpers.ContainsFlag(args, "--strict") {
+		return &ExitError{Code: constants.ExitUnreadable, Err: fmt.Errorf("refusing to copy: %d selected file(s) had problems and --strict is set", len(b.Diagnostics))}
+	}
+	if len(b.Redactions) > 0 && helpers.ContainsFlag(args, "--redact-strict") {
+		return &ExitError{Code: constants.ExitRedacted, Err: fmt.Errorf("refusing to copy: secrets were detected in %d file(s) and --redact-strict is set", len(b.Redactions))}
 	}
 
-	ui.DisplayCopySuccess()
-	ui.DisplaySuccess("Code context generated and copied successfully!")
+	clipboardErr, err := deliver(b, args)
+	if err != nil {
+		return err
+	}
 
 	if helpers.ContainsFlag(args, "--help") {
-		ui.DisplayHelp()
+		ui.DisplayHelp(registry)
 	}
 
+	if nonInteractive {
+		switch {
+		case clipboardErr != nil:
+			return &ExitError{Code: constants.ExitClipboard, Err: fmt.Errorf("failed to copy code context to clipboard: %v", clipboardErr)}
+		case len(b.Diagnostics) > 0:
+			return &ExitError{Code: constants.ExitUnreadable, Err: fmt.Errorf("%d selected file(s) could not be read or counted", len(b.Diagnostics))}
+		case len(b.Redactions) > 0:
+			return &ExitError{Code: constants.ExitRedacted, Err: fmt.Errorf("secrets were redacted in %d file(s)", len(b.Redactions))}
+		}
+	}
 	return nil
 }
 
-// generateCodeContext generates the code context and calculates token counts for the selected files.
-func generateCodeContext(rootDir string, selectedFiles []string) (string, int, map[string]int, error) {
-	var codeContext strings.Builder
-	totalTokens := 0
-	fileTokenCounts := make(map[string]int)
+// deliver writes the code context to the --output file, or copies it to the
+// clipboard, falling back to a file when the clipboard fails. The clipboard
+// failure is returned separately from errors that stop the run.
+func deliver(b *bundle.Bundle, args []string) (clipboardErr error, err error) {
+	if outputPath, ok := helpers.GetFlagValue(args, "--output"); ok {
+		if outputPath == "" {
+			return nil, fmt.Errorf("--output requires a file name")
+		}
+		if err := helpers.WriteToFile(b.Text, outputPath); err != nil {
+			return nil, fmt.Errorf("failed to write code context to file: %v", err)
+		}
+		ui.DisplaySuccess(fmt.Sprintf("Code context generated and written to %s", outputPath))
+		return nil, nil
+	}
 
-	// Calculate token counts for selected files
-	for _, file := range selectedFiles {
-		content, err := helpers.ReadFileContent(file)
-		if err != nil {
-			fmt.Printf("Warning: failed to read file %s: %v\n", file, err)
-			continue
+	if clipboardErr := helpers.CopyToClipboard(b.Text); clipboardErr != nil {
+		ui.DisplayError(fmt.Errorf("failed to copy code context to clipboard: %v", clipboardErr))
+		if err := helpers.WriteToFile(b.Text, fallbackOutput); err != nil {
+			return clipboardErr, fmt.Errorf("failed to write code context to file: %v", err)
 		}
+		ui.DisplaySuccess("Code context generated and written to " + fallbackOutput)
+		return clipboardErr, nil
+	}
+
+	ui.DisplayCopySuccess()
+	ui.DisplaySuccess("Code context generated and copied successfully!")
+	return nil, nil
+}
 
-		tokenCount, err := helpers.CountTokens(content)
+// selectFiles chooses the files a run copies. bundle.Select makes the
+// --files, --symbol, --grep and default selections; files listed on standard
+// input, recalled from a saved selection or picked with -m are passed to it
+// as explicit files.
+func selectFiles(ctx context.Context, opts bundle.Options, args []string, recalled *selections.Selection) (*bundle.Selection, error) {
+	if helpers.ContainsFlag(args, "--stdin") {
+		stdinPaths, err := helpers.ReadPaths(os.Stdin)
 		if err != nil {
-			fmt.Printf("Warning: failed to count tokens for file %s: %v\n", file, err)
-			continue
+			return nil, err
+		}
+		opts.Files = append(opts.Files, stdinPaths...)
+	}
+	if len(opts.Files) > 0 {
+		return bundle.Select(ctx, opts)
+	}
+
+	var recalledFiles []string
+	if recalled != nil {
+		var err error
+		if recalledFiles, opts.Outline, opts.Truncate, err = recallFiles(opts.Root, recalled); err != nil {
+			return nil, err
 		}
+	}
+	if !helpers.ContainsFlag(args, "-m") {
+		opts.Files = recalledFiles
+		return bundle.Select(ctx, opts)
+	}
+
+	opts.Files = []string{opts.Root}
+	selection, err := bundle.Select(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := pickFiles(ctx, selection, recalledFiles); err != nil {
+		return nil, fmt.Errorf("failed to perform manual file selection: %v", err)
+	}
+	return selection, nil
+}
+
+// newOptions builds the bundle options selected by the flags in args.
+func newOptions(rootDir string, args []string, cfg *config.Config) (bundle.Options, error) {
+	opts := bundle.Options{
+		Root:               rootDir,
+		Config:             cfg,
+		Files:              helpers.GetFlagValues(args, "--files"),
+		Symbols:            helpers.GetFlagValues(args, "--symbol"),
+		BinaryPlaceholders: helpers.ContainsFlag(args, "--binary-placeholders"),
+		DisableSummaries:   helpers.ContainsFlag(args, "--no-summarize"),
+		KeepHeaders:        helpers.ContainsFlag(args, "--keep-headers"),
+		IncludeGenerated:   helpers.ContainsFlag(args, "--include-generated"),
+		OutlineGenerated:   helpers.ContainsFlag(args, "--generated-outline"),
+		AllowSensitive:     helpers.ContainsFlag(args, "--allow-sensitive"),
+		DisableRedaction:   helpers.ContainsFlag(args, "--no-redact"),
+	}
+	if language := cfg.Registry().Selected(args); language != nil {
+		opts.Language = language.Name
+	}
 
-		totalTokens += tokenCount
-		fileTokenCounts[file] = tokenCount
+	for flag, value := range map[string]*int{
+		"--max-bytes":        &opts.MaxBytes,
+		"--max-lines":        &opts.MaxLines,
+		"--max-file-tokens":  &opts.MaxFileTokens,
+		"--max-file-size":    &opts.MaxFileSize,
+		"--notebook-outputs": &opts.NotebookOutputLines,
+		"--grep-context":     &opts.GrepContext,
+	} {
+		var err error
+		if *value, err = helpers.GetIntFlag(args, flag); err != nil {
+			return bundle.Options{}, err
+		}
 	}
 
-	// Build code context
-	for _, file := range selectedFiles {
-		content, err := helpers.ReadFileContent(file)
+	if _, ok := helpers.GetFlagValue(args, "--summarize-over"); ok {
+		summarizeOver, err := helpers.GetIntFlag(args, "--summarize-over")
 		if err != nil {
-			fmt.Printf("Warning: failed to read file %s: %v\n", file, err)
-			continue
+			return bundle.Options{}, err
 		}
+		opts.SummarizeOver = summarizeOver
+		opts.DisableSummaries = opts.DisableSummaries || summarizeOver == 0
+	}
+
+	if _, ok := helpers.GetFlagValue(args, "--max-file-size"); ok {
+		opts.DisableFileSizeLimit = opts.MaxFileSize == 0
+	}
 
-		relPath := strings.TrimPrefix(file, rootDir+"/")
-		codeContext.WriteString(fmt.Sprintf("\n%s\n\n", relPath))
-		codeContext.WriteString(content)
-		codeContext.WriteString("\n")
+	if grepPattern, ok := helpers.GetFlagValue(args, "--grep"); ok {
+		if grepPattern == "" {
+			return bundle.Options{}, fmt.Errorf("--grep requires a pattern")
+		}
+		opts.Grep = grepPattern
 	}
 
-	var output strings.Builder
-	output.WriteString(fmt.Sprintf("Root Directory: %s\n\n", rootDir))
-	output.WriteString(fmt.Sprintf("Total Tokens: %d\n\n", totalTokens))
-	output.WriteString("Code Context:\n")
-	output.WriteString(codeContext.String())
+	if helpers.ContainsFlag(args, "--minify") {
+		opts.Minify = minifyOptions(helpers.ContainsFlag(args, "--keep-license"), helpers.ContainsFlag(args, "--keep-doc-comments"), helpers.ContainsFlag(args, "--keep-inline-comments"))
+	}
 
-	return output.String(), totalTokens, fileTokenCounts, nil
+	return opts, nil
 }
 
-// getExcludedFiles retrieves the excluded files based on the ignored directories.
-func getExcludedFiles(rootDir string) []string {
-	var excludedFiles []string
+// minifyOptions returns the minification settings that strip all comments
+// except the kinds kept.
+func minifyOptions(keepLicense, keepDoc, keepInline bool) *minify.Options {
+	opts := minify.All()
+	opts.StripLicense = !keepLicense
+	opts.StripDoc = !keepDoc
+	opts.StripInline = !keepInline
+	return &opts
+}
 
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			for _, dir := range constants.IgnoredDirs {
-				if strings.Contains(path, dir) {
-					excludedFiles = append(excludedFiles, path)
-					break
-				}
-			}
+// tokenCounts returns the absolute paths of the bundle's files with their
+// token counts and notes, as the tree and file listings show them.
+func tokenCounts(b *bundle.Bundle) ([]string, map[string]int, map[string]string) {
+	files := make([]string, 0, len(b.Files))
+	counts := make(map[string]int, len(b.Files))
+	notes := make(map[string]string)
+	for _, file := range b.Files {
+		files = append(files, file.AbsPath)
+		counts[file.AbsPath] = file.Tokens
+		if note := file.Notes(); note != "" {
+			notes[file.AbsPath] = note
 		}
-		return nil
+	}
+	return files, counts, notes
+}
+
+// LoadRegistry returns the language registry for rootDir, including any
+// languages added or overridden in the user and project configuration.
+func LoadRegistry(rootDir string) (*languages.Registry, error) {
+	cfg, err := config.Load(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	return cfg.Registry(), nil
+}
+
+// runPicker opens the full-screen file picker.
+var runPicker = picker.Run
+
+// pickFiles lets the user choose from the selected files in the full-screen
+// picker, starting from the preselected files, and makes the chosen files,
+// with any downgraded to outlines or truncated content, the selection.
+func pickFiles(ctx context.Context, selection *bundle.Selection, selected []string) error {
+	choices, err := runPicker(selection.Root, selection.Files, picker.Options{
+		Title:     "Select files to include",
+		Budget:    constants.TokenLimit,
+		Selected:  selected,
+		Modes:     modes(selection.Settings, selected),
+		Downgrade: true,
+		Count:     counter(ctx, selection),
 	})
+	if err != nil {
+		return err
+	}
+	applyChoices(selection, choices)
+	return nil
+}
 
+// trimToBudget reopens the picker on an over-budget bundle so the user can
+// uncheck files or downgrade them to outlines or truncated content while
+// watching the total, and regenerates the bundle from what they confirm.
+func trimToBudget(ctx context.Context, selection *bundle.Selection, b *bundle.Bundle) (*bundle.Bundle, error) {
+	files, counts, _ := tokenCounts(b)
+	choices, err := runPicker(selection.Root, files, picker.Options{
+		Title:     "Over budget: uncheck or downgrade files",
+		Budget:    constants.TokenLimit,
+		Selected:  files,
+		Modes:     modes(selection.Settings, files),
+		Downgrade: true,
+		Tokens:    counts,
+		Count:     counter(ctx, selection),
+	})
 	if err != nil {
-		fmt.Printf("Warning: failed to get excluded files: %v\n", err)
+		return nil, err
+	}
+
+	applyChoices(selection, choices)
+	return selection.Generate(ctx)
+}
+
+// counter returns a function that counts a file's tokens as the selection
+// would include it in the given mode. Problems are reported by the final
+// generation.
+func counter(ctx context.Context, selection *bundle.Selection) func(string, picker.Mode) (int, error) {
+	return func(file string, mode picker.Mode) (int, error) {
+		settings := selection.Settings
+		settings.Outlines = map[string]bool{file: mode == picker.Outline}
+		settings.Truncated = map[string]bool{file: mode == picker.Truncated}
+		settings.DedupeHeaders = false
+
+		b, err := bundle.Generate(ctx, selection.Root, []string{file}, settings)
+		if err != nil {
+			return 0, err
+		}
+		if len(b.Files) == 0 {
+			return 0, fmt.Errorf("%s is not included", file)
+		}
+		return b.Files[0].Tokens, nil
 	}
+}
+
+// modes returns the picker modes of the downgraded files among files.
+func modes(settings bundle.Settings, files []string) map[string]picker.Mode {
+	modes := make(map[string]picker.Mode)
+	for _, file := range files {
+		switch {
+		case settings.Outlines[file]:
+			modes[file] = picker.Outline
+		case settings.Truncated[file]:
+			modes[file] = picker.Truncated
+		}
+	}
+	return modes
+}
 
-	return excludedFiles
+// applyChoices makes the chosen files the selection, recording the mode of each.
+func applyChoices(selection *bundle.Selection, choices []picker.Choice) {
+	files := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		selection.Settings.Outlines[choice.Path] = choice.Mode == picker.Outline
+		selection.Settings.Truncated[choice.Path] = choice.Mode == picker.Truncated
+		files = append(files, choice.Path)
+	}
+	selection.Files = files
 }
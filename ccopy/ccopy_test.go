@@ -0,0 +1,229 @@
+package ccopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codecopy/bundle"
+	"codecopy/constants"
+	"codecopy/picker"
+)
+
+// longGoFile returns a Go file with n small functions.
+func longGoFile(n int) string {
+	var b strings.Builder
+	b.WriteString("package pkg\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "\n// F%d returns %d.\nfunc F%d() int {\n\treturn %d\n}\n", i, i, i, i)
+	}
+	return b.String()
+}
+
+// stubPicker replaces the picker for the duration of the test with one that
+// records its options and returns the given choices and error.
+func stubPicker(t *testing.T, choices []picker.Choice, err error) *picker.Options {
+	t.Helper()
+	var opened picker.Options
+	previous := runPicker
+	runPicker = func(rootDir string, files []string, opts picker.Options) ([]picker.Choice, error) {
+		opened = opts
+		return choices, err
+	}
+	t.Cleanup(func() { runPicker = previous })
+	return &opened
+}
+
+// newTrimFixture selects three files, a.go, b.go and c.go, and generates
+// their full bundle, returning it with the selection and the files' absolute
+// paths.
+func newTrimFixture(t *testing.T) (*bundle.Selection, *bundle.Bundle, map[string]string) {
+	t.Helper()
+	ws := newTestWorkspace(t, map[string]string{
+		"a.go": "package pkg\n\nfunc A() {}\n",
+		"b.go": longGoFile(40),
+		"c.go": longGoFile(60),
+	})
+	selection, err := bundle.Select(context.Background(), bundle.Options{Root: ws.rootDir, Config: ws.cfg, Files: []string{"a.go", "b.go", "c.go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[string]string)
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		paths[name] = filepath.Join(ws.rootDir, name)
+	}
+	b, err := selection.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return selection, b, paths
+}
+
+func TestTrimToBudget(t *testing.T) {
+	selection, full, paths := newTrimFixture(t)
+	opened := stubPicker(t, []picker.Choice{
+		{Path: paths["b.go"], Mode: picker.Outline},
+		{Path: paths["c.go"], Mode: picker.Truncated},
+	}, nil)
+
+	trimmed, err := trimToBudget(context.Background(), selection, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fullFiles, fullCounts, _ := tokenCounts(full)
+	if !reflect.DeepEqual(opened.Selected, fullFiles) || !reflect.DeepEqual(opened.Tokens, fullCounts) {
+		t.Errorf("picker opened with %v and %v, want the full selection and its counts", opened.Selected, opened.Tokens)
+	}
+
+	trimmedFiles, trimmedCounts, trimmedNotes := tokenCounts(trimmed)
+	if want := []string{paths["b.go"], paths["c.go"]}; !reflect.DeepEqual(trimmedFiles, want) {
+		t.Fatalf("files = %v, want %v without the unchecked a.go", trimmedFiles, want)
+	}
+	if strings.Contains(trimmed.Text, "func A()") {
+		t.Error("unchecked a.go is still in the code context")
+	}
+
+	count := counter(context.Background(), selection)
+	for _, tt := range []struct {
+		name string
+		mode picker.Mode
+		note string
+	}{
+		{"b.go", picker.Outline, "outline"},
+		{"c.go", picker.Truncated, "truncated"},
+	} {
+		file := paths[tt.name]
+		want, err := count(file, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := trimmedCounts[file]; got != want || got >= fullCounts[file] {
+			t.Errorf("%s has %d tokens, want %d, recounted as %s and below %d in full", tt.name, got, want, tt.mode, fullCounts[file])
+		}
+		if !strings.Contains(trimmedNotes[file], tt.note) {
+			t.Errorf("%s notes = %q, want %s", tt.name, trimmedNotes[file], tt.note)
+		}
+	}
+	if !selection.Settings.Outlines[paths["b.go"]] || !selection.Settings.Truncated[paths["c.go"]] {
+		t.Error("downgrades were not recorded in the selection")
+	}
+	if trimmed.TotalTokens >= full.TotalTokens {
+		t.Errorf("total = %d, want below the untrimmed %d", trimmed.TotalTokens, full.TotalTokens)
+	}
+}
+
+func TestTrimToBudgetCancelled(t *testing.T) {
+	selection, full, paths := newTrimFixture(t)
+	stubPicker(t, nil, picker.ErrCancelled)
+
+	trimmed, err := trimToBudget(context.Background(), selection, full)
+	if !errors.Is(err, picker.ErrCancelled) {
+		t.Fatalf("err = %v, want picker.ErrCancelled", err)
+	}
+	if trimmed != nil {
+		t.Error("a cancelled picker produced a code context to copy")
+	}
+	for _, file := range paths {
+		if selection.Settings.Outlines[file] || selection.Settings.Truncated[file] {
+			t.Errorf("cancelled picker downgraded %s", file)
+		}
+	}
+}
+
+func TestApplyChoices(t *testing.T) {
+	selection, _, paths := newTrimFixture(t)
+	selection.Settings.Outlines[paths["a.go"]] = true
+	selection.Settings.Truncated[paths["c.go"]] = true
+
+	applyChoices(selection, []picker.Choice{
+		{Path: paths["a.go"], Mode: picker.Full},
+		{Path: paths["b.go"], Mode: picker.Truncated},
+		{Path: paths["c.go"], Mode: picker.Outline},
+	})
+
+	if want := []string{paths["a.go"], paths["b.go"], paths["c.go"]}; !reflect.DeepEqual(selection.Files, want) {
+		t.Errorf("files = %v, want %v", selection.Files, want)
+	}
+	tests := []struct {
+		name                string
+		outlined, truncated bool
+	}{
+		{"a.go", false, false},
+		{"b.go", false, true},
+		{"c.go", true, false},
+	}
+	for _, tt := range tests {
+		file := paths[tt.name]
+		if selection.Settings.Outlines[file] != tt.outlined || selection.Settings.Truncated[file] != tt.truncated {
+			t.Errorf("%s: outlined %v, truncated %v, want %v, %v", tt.name, selection.Settings.Outlines[file], selection.Settings.Truncated[file], tt.outlined, tt.truncated)
+		}
+	}
+}
+
+func TestRunRedactStrict(t *testing.T) {
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n",
+		"main.go": "package main\n\nconst apiKey = \"f8Kq2Lm9Zx7Rt4Wp\"\n",
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		written bool
+	}{
+		{"redact strict refuses", []string{"--redact-strict", "--output", "out.md"}, false},
+		{"non-interactive writes and reports", []string{"--output", "out.md"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newTestWorkspace(t, files)
+			t.Chdir(ws.rootDir)
+
+			err := Run(tt.args)
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) || exitErr.Code != constants.ExitRedacted {
+				t.Fatalf("Run = %v, want exit code %d", err, constants.ExitRedacted)
+			}
+			_, statErr := os.Stat(filepath.Join(ws.rootDir, "out.md"))
+			if written := statErr == nil; written != tt.written {
+				t.Errorf("out.md written = %v, want %v", written, tt.written)
+			}
+		})
+	}
+}
+
+func TestOverBudgetPolicy(t *testing.T) {
+	tests := []struct {
+		args           []string
+		nonInteractive bool
+		want           string
+		wantErr        string
+	}{
+		{nil, false, policyPick, ""},
+		{nil, true, policyFail, ""},
+		{[]string{"--over-budget", "pick"}, false, policyPick, ""},
+		{[]string{"--over-budget", "pick"}, true, "", "needs an interactive terminal"},
+		{[]string{"--over-budget", "fit"}, true, policyFit, ""},
+		{[]string{"--over-budget", "fail"}, false, policyFail, ""},
+		{[]string{"--over-budget", "shrink"}, false, "", "unknown --over-budget policy"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v non-interactive %v", tt.args, tt.nonInteractive), func(t *testing.T) {
+			policy, err := overBudgetPolicy(tt.args, tt.nonInteractive)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || policy != tt.want {
+				t.Errorf("policy = %q, %v, want %q", policy, err, tt.want)
+			}
+		})
+	}
+}
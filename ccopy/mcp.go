@@ -0,0 +1,357 @@
+package ccopy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"codecopy/bundle"
+	"codecopy/helpers"
+)
+
+// mcpProtocolVersions are the Model Context Protocol versions the server
+// speaks, newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// maxBudgetErrorFiles is the number of largest files listed when a
+// build_context call is over its budget.
+const maxBudgetErrorFiles = 10
+
+// rpcRequest is a JSON-RPC request, or a notification when it has no ID.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC response carrying either a result or an error.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool describes a tool in a tools/list response.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// toolResult is the result of a tools/call request. Failures the model can
+// act on, such as a context over budget, are reported with IsError rather
+// than as protocol errors.
+type toolResult struct {
+	Content []toolContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+type toolContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// fileSelection is the arguments of the list_files and get_tree tools.
+type fileSelection struct {
+	Globs []string `json:"globs"`
+	All   bool     `json:"all"`
+}
+
+// mcpTools lists the tools the server offers.
+var mcpTools = []mcpTool{
+	{
+		Name:        "list_files",
+		Description: "List the files codecopy would include by default, or all files, with the tokens each takes.",
+		InputSchema: selectionSchema,
+	},
+	{
+		Name:        "get_tree",
+		Description: "Show the directory tree of the files codecopy would include by default, or all files, with token counts.",
+		InputSchema: selectionSchema,
+	},
+	{
+		Name:        "read_files",
+		Description: "Read files or directories in full, without summarizing data files or removing shared license headers, as Markdown with a fenced block per file. Secrets are redacted and binary files left out.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"paths": stringArraySchema("Files or directories relative to the repository root."),
+			},
+			"required": []string{"paths"},
+		},
+	},
+	{
+		Name:        "build_context",
+		Description: "Build a code context bundle from paths and globs, or from codecopy's default selection, within a token budget.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"paths":  stringArraySchema("Files or directories relative to the repository root."),
+				"globs":  stringArraySchema("Patterns matched against paths relative to the root, as in .gitignore."),
+				"format": map[string]any{"type": "string", "enum": []bundle.Format{bundle.FormatText, bundle.FormatMarkdown, bundle.FormatJSON}, "description": "Output format; text by default."},
+				"budget": map[string]any{"type": "integer", "minimum": 0, "description": "Fail with the largest files listed if the bundle has more tokens; 0 means no budget."},
+				"strict": map[string]any{"type": "boolean", "description": "Fail if any selected file cannot be read or counted instead of leaving it out."},
+				"compression": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"minify":               map[string]any{"type": "boolean", "description": "Strip comments and blank lines and reduce indentation."},
+						"keep_license":         map[string]any{"type": "boolean"},
+						"keep_doc_comments":    map[string]any{"type": "boolean"},
+						"keep_inline_comments": map[string]any{"type": "boolean"},
+						"keep_headers":         map[string]any{"type": "boolean", "description": "Keep license headers repeated across files."},
+						"max_bytes":            map[string]any{"type": "integer", "minimum": 0},
+						"max_lines":            map[string]any{"type": "integer", "minimum": 0},
+						"max_file_tokens":      map[string]any{"type": "integer", "minimum": 0},
+						"summarize_over":       map[string]any{"type": "integer", "minimum": 0, "description": "Summarize data files and lockfiles over this many tokens; 0 disables summaries."},
+						"outline":              stringArraySchema("Files reduced to their declarations."),
+						"truncate":             stringArraySchema("Files reduced to their head and tail."),
+					},
+				},
+			},
+		},
+	},
+}
+
+var selectionSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"globs": stringArraySchema("Only list files whose path relative to the root matches one of these patterns."),
+		"all":   map[string]any{"type": "boolean", "description": "List every file instead of the default selection."},
+	},
+}
+
+func stringArraySchema(description string) map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": description}
+}
+
+// ServeMCP runs a Model Context Protocol server for the current directory,
+// reading JSON-RPC messages from standard input and writing responses to
+// standard output until the input ends.
+func ServeMCP() error {
+	ws, err := newWorkspace()
+	if err != nil {
+		return err
+	}
+	return serveMCP(ws, os.Stdin, os.Stdout)
+}
+
+// serveMCP answers newline-delimited JSON-RPC messages from in on out.
+func serveMCP(ws *workspace, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	encoder := json.NewEncoder(out)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if response := ws.handleMessage(line); response != nil {
+				if err := encoder.Encode(response); err != nil {
+					return fmt.Errorf("failed to write response: %v", err)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read request: %v", err)
+		}
+	}
+}
+
+// handleMessage answers one JSON-RPC message, returning nil for notifications.
+func (ws *workspace) handleMessage(line []byte) *rpcResponse {
+	var request rpcRequest
+	if err := json.Unmarshal(line, &request); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}
+	}
+	if len(request.ID) == 0 {
+		return nil
+	}
+
+	response := &rpcResponse{JSONRPC: "2.0", ID: request.ID}
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		response.Error = &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+		return response
+	}
+
+	switch request.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(request.Params, &params)
+		version := mcpProtocolVersions[0]
+		if helpers.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		response.Result = map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "codecopy", "version": buildVersion()},
+		}
+	case "ping":
+		response.Result = map[string]any{}
+	case "tools/list":
+		response.Result = map[string]any{"tools": mcpTools}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			response.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			return response
+		}
+		result, err := ws.callTool(params.Name, params.Arguments)
+		if err != nil {
+			response.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			return response
+		}
+		response.Result = result
+	default:
+		response.Error = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", request.Method)}
+	}
+	return response
+}
+
+// callTool runs a tool. Unknown tools and malformed arguments are returned
+// as errors; failures of the tool itself are reported in the result.
+func (ws *workspace) callTool(name string, arguments json.RawMessage) (*toolResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	decode := func(v any) error {
+		decoder := json.NewDecoder(bytes.NewReader(arguments))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("invalid arguments for %s: %v", name, err)
+		}
+		return nil
+	}
+
+	var text string
+	var err error
+	switch name {
+	case "list_files", "get_tree":
+		var selection fileSelection
+		if err := decode(&selection); err != nil {
+			return nil, err
+		}
+		if name == "list_files" {
+			text, err = ws.listTool(selection)
+		} else {
+			text, err = ws.treeTool(selection)
+		}
+	case "read_files":
+		var args struct {
+			Paths []string `json:"paths"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		if len(args.Paths) == 0 {
+			return nil, errors.New("read_files requires paths")
+		}
+		noSummaries := 0
+		text, err = ws.buildTool(bundleRequest{
+			Paths:       args.Paths,
+			Format:      string(bundle.FormatMarkdown),
+			Compression: compressionRequest{KeepHeaders: true, SummarizeOver: &noSummaries},
+		})
+	case "build_context":
+		var request bundleRequest
+		if err := decode(&request); err != nil {
+			return nil, err
+		}
+		text, err = ws.buildTool(request)
+	default:
+		return nil, fmt.Errorf("unknown tool %q", name)
+	}
+
+	if err != nil {
+		return &toolResult{Content: []toolContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	return &toolResult{Content: []toolContent{{Type: "text", Text: text}}}, nil
+}
+
+// listTool lists the selected files with their token counts.
+func (ws *workspace) listTool(selection fileSelection) (string, error) {
+	files, counts, errs, err := ws.countFiles(listing(selection.Globs, selection.All))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	total := 0
+	for _, file := range files {
+		if err := errs[file]; err != nil {
+			b.WriteString(fmt.Sprintf("%s\terror: %v\n", helpers.RelativePath(ws.rootDir, file), err))
+			continue
+		}
+		total += counts[file]
+		b.WriteString(fmt.Sprintf("%s\t%d\n", helpers.RelativePath(ws.rootDir, file), counts[file]))
+	}
+	b.WriteString(fmt.Sprintf("%d files, %d tokens\n", len(files), total))
+	return b.String(), nil
+}
+
+// treeTool shows the directory tree of the selected files with token counts.
+func (ws *workspace) treeTool(selection fileSelection) (string, error) {
+	files, counts, _, err := ws.countFiles(listing(selection.Globs, selection.All))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(helpers.BuildTreeWithTokenCounts(ws.rootDir, files, counts, nil), "\n") + "\n", nil
+}
+
+// buildTool builds and renders a code context. Over budget, it lists the
+// largest files so the caller can narrow the request.
+func (ws *workspace) buildTool(request bundleRequest) (string, error) {
+	b, err := ws.build(request)
+	var budgetErr *bundle.BudgetError
+	if errors.As(err, &budgetErr) {
+		var text strings.Builder
+		text.WriteString(budgetErr.Error())
+		text.WriteString(". Narrow the paths, or use compression (minify, outline, truncate, max_file_tokens). Largest files:\n")
+		for i, file := range b.Largest() {
+			if i == maxBudgetErrorFiles {
+				break
+			}
+			text.WriteString(fmt.Sprintf("%s\t%d\n", file.Path, file.Tokens))
+		}
+		return "", errors.New(text.String())
+	}
+	if err != nil {
+		return "", err
+	}
+	format, _ := bundle.ParseFormat(request.Format)
+	return b.Render(format)
+}
+
+// buildVersion returns the module version codecopy was built from.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,385 @@
+package ccopy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mcpResponse is a decoded JSON-RPC response with its result kept raw.
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+// runMCP feeds the scripted messages, one per line, to the server and
+// returns its responses in order.
+func runMCP(t *testing.T, ws *workspace, messages ...string) []mcpResponse {
+	t.Helper()
+	var out bytes.Buffer
+	if err := serveMCP(ws, strings.NewReader(strings.Join(messages, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	return decodeResponses(t, out.Bytes())
+}
+
+// decodeResponses parses output that must consist of JSON-RPC responses only,
+// one per line.
+func decodeResponses(t *testing.T, output []byte) []mcpResponse {
+	t.Helper()
+	var responses []mcpResponse
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var response mcpResponse
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&response); err != nil || response.JSONRPC != "2.0" {
+			t.Fatalf("output line is not a JSON-RPC response: %q", scanner.Text())
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// toolText returns the text and error flag of a tools/call result.
+func toolText(t *testing.T, response mcpResponse) (string, bool) {
+	t.Helper()
+	if response.Error != nil {
+		t.Fatalf("tools/call failed: %s", response.Error.Message)
+	}
+	var result toolResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Type != "text" {
+		t.Fatalf("unexpected content: %+v", result.Content)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+// license is a license header shared by several files.
+const license = "// Copyright 2024 Example Authors.\n// Licensed under the Apache License, Version 2.0.\n\n"
+
+// largeJSON returns a JSON object with n numbered keys.
+func largeJSON(n int) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "  \"key%d\": %d", i, i)
+	}
+	b.WriteString("\n}\n")
+	return b.String()
+}
+
+func TestMCPInitialize(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"2025-03-26", "2025-03-26"},
+		{"2024-11-05", "2024-11-05"},
+		{"1999-01-01", mcpProtocolVersions[0]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			responses := runMCP(t, ws, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+tt.requested+`","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			var result struct {
+				ProtocolVersion string         `json:"protocolVersion"`
+				Capabilities    map[string]any `json:"capabilities"`
+				ServerInfo      struct {
+					Name string `json:"name"`
+				} `json:"serverInfo"`
+			}
+			if err := json.Unmarshal(responses[0].Result, &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.ProtocolVersion != tt.want {
+				t.Errorf("protocolVersion = %q, want %q", result.ProtocolVersion, tt.want)
+			}
+			if _, ok := result.Capabilities["tools"]; !ok {
+				t.Errorf("capabilities = %v, want tools", result.Capabilities)
+			}
+			if result.ServerInfo.Name != "codecopy" {
+				t.Errorf("serverInfo.name = %q, want codecopy", result.ServerInfo.Name)
+			}
+		})
+	}
+}
+
+func TestMCPToolsList(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+
+	responses := runMCP(t, ws, `{"jsonrpc":"2.0","id":"list","method":"tools/list"}`)
+	if len(responses) != 1 || string(responses[0].ID) != `"list"` {
+		t.Fatalf("responses = %+v, want one with id \"list\"", responses)
+	}
+	var result struct {
+		Tools []mcpTool `json:"tools"`
+	}
+	if err := json.Unmarshal(responses[0].Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("%s input schema type = %v, want object", tool.Name, tool.InputSchema["type"])
+		}
+	}
+	if got, want := strings.Join(names, ","), "list_files,get_tree,read_files,build_context"; got != want {
+		t.Errorf("tools = %s, want %s", got, want)
+	}
+}
+
+func TestMCPToolsCall(t *testing.T) {
+	files := map[string]string{
+		"bad.txt":    "hello \xff world\n",
+		"data.json":  largeJSON(3000),
+		"a/one.go":   license + "package a\n",
+		"a/two.go":   license + "package a\n",
+		"a/three.go": license + "package a\n",
+	}
+	for name, content := range testFiles {
+		files[name] = content
+	}
+	ws := newTestWorkspace(t, files)
+
+	tests := []struct {
+		name     string
+		params   string
+		isError  bool
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "list_files",
+			params:   `{"name":"list_files","arguments":{"all":true}}`,
+			contains: []string{"main.go\t", "pkg/util.go\t", "tokens"},
+		},
+		{
+			name:     "list_files with globs",
+			params:   `{"name":"list_files","arguments":{"globs":["pkg/**"]}}`,
+			contains: []string{"pkg/util.go\t", "1 files"},
+			excludes: []string{"main.go"},
+		},
+		{
+			name:     "get_tree",
+			params:   `{"name":"get_tree","arguments":{"all":true}}`,
+			contains: []string{"main.go", "util.go"},
+		},
+		{
+			name:     "read_files",
+			params:   `{"name":"read_files","arguments":{"paths":["pkg"]}}`,
+			contains: []string{"## pkg/util.go", "func Add"},
+		},
+		{
+			name:     "read_files without summaries",
+			params:   `{"name":"read_files","arguments":{"paths":["data.json"]}}`,
+			contains: []string{`"key1500": 1500`},
+			excludes: []string{"Schema:"},
+		},
+		{
+			name:     "read_files with shared headers",
+			params:   `{"name":"read_files","arguments":{"paths":["a"]}}`,
+			contains: []string{"## a/two.go\n\n```go\n" + license},
+			excludes: []string{"Shared Headers"},
+		},
+		{
+			name:     "build_context summarizes",
+			params:   `{"name":"build_context","arguments":{"paths":["data.json"]}}`,
+			contains: []string{"Schema:"},
+		},
+		{
+			name:     "build_context",
+			params:   `{"name":"build_context","arguments":{"paths":["main.go"],"format":"json"}}`,
+			contains: []string{`"path": "main.go"`},
+		},
+		{
+			name:     "build_context over budget",
+			params:   `{"name":"build_context","arguments":{"paths":["main.go","pkg"],"budget":1}}`,
+			isError:  true,
+			contains: []string{"over the budget of 1", "Largest files:", "main.go\t", "pkg/util.go\t"},
+		},
+		{
+			name:     "build_context strict",
+			params:   `{"name":"build_context","arguments":{"paths":["main.go","bad.txt"],"strict":true}}`,
+			isError:  true,
+			contains: []string{"bad.txt"},
+		},
+		{
+			name:     "build_context outside the repository",
+			params:   `{"name":"build_context","arguments":{"paths":["../secret"]}}`,
+			isError:  true,
+			contains: []string{"outside the repository"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := runMCP(t, ws, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":`+tt.params+`}`)
+			if len(responses) != 1 || string(responses[0].ID) != "7" {
+				t.Fatalf("responses = %+v, want one with id 7", responses)
+			}
+			text, isError := toolText(t, responses[0])
+			if isError != tt.isError {
+				t.Errorf("isError = %v, want %v: %s", isError, tt.isError, text)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("result is missing %q:\n%s", want, text)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("result contains %q:\n%s", unwanted, text)
+				}
+			}
+		})
+	}
+}
+
+func TestMCPErrors(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+
+	tests := []struct {
+		name    string
+		message string
+		id      string
+		code    int
+	}{
+		{"malformed JSON", `{"jsonrpc":"2.0","id":1,"method":`, "null", rpcParseError},
+		{"unknown method", `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`, "2", rpcMethodNotFound},
+		{"wrong version", `{"jsonrpc":"1.0","id":3,"method":"ping"}`, "3", rpcInvalidRequest},
+		{"missing method", `{"jsonrpc":"2.0","id":4}`, "4", rpcInvalidRequest},
+		{"unknown tool", `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"delete_files"}}`, "5", rpcInvalidParams},
+		{"unknown argument", `{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"list_files","arguments":{"recursive":true}}}`, "6", rpcInvalidParams},
+		{"read_files without paths", `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"read_files","arguments":{}}}`, "7", rpcInvalidParams},
+		{"malformed params", `{"jsonrpc":"2.0","id":8,"method":"tools/call","params":[1]}`, "8", rpcInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := runMCP(t, ws, tt.message)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			response := responses[0]
+			if string(response.ID) != tt.id {
+				t.Errorf("id = %s, want %s", response.ID, tt.id)
+			}
+			if response.Error == nil || response.Error.Code != tt.code {
+				t.Fatalf("error = %+v, want code %d", response.Error, tt.code)
+			}
+			if response.Result != nil {
+				t.Errorf("error response carries a result: %s", response.Result)
+			}
+		})
+	}
+}
+
+func TestMCPNotifications(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+
+	responses := runMCP(t, ws,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+	)
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2 for the requests only", len(responses))
+	}
+	if string(responses[0].ID) != "1" || string(responses[1].ID) != "2" {
+		t.Errorf("response ids = %s, %s, want 1, 2", responses[0].ID, responses[1].ID)
+	}
+}
+
+func TestMCPLastLineWithoutNewline(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+
+	var out bytes.Buffer
+	if err := serveMCP(ws, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if responses := decodeResponses(t, out.Bytes()); len(responses) != 1 {
+		t.Errorf("got %d responses, want 1", len(responses))
+	}
+}
+
+// TestServeMCPStdout runs the server on the process's standard streams and
+// checks that standard output carries nothing but JSON-RPC responses.
+func TestServeMCPStdout(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(ws.rootDir); err != nil {
+		t.Fatal(err)
+	}
+	stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+	})
+
+	dir := t.TempDir()
+	script := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"list_files"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get_tree"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"read_files","arguments":{"paths":["main.go"]}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"build_context","arguments":{"budget":1}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"build_context","arguments":{"paths":["missing.go"]}}}`,
+		`not json`,
+	}, "\n") + "\n"
+	files := make(map[string]*os.File)
+	for _, name := range []string{"stdin", "stdout", "stderr"} {
+		if files[name], err = os.Create(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		defer files[name].Close()
+	}
+	if _, err := files["stdin"].WriteString(script); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files["stdin"].Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdin, os.Stdout, os.Stderr = files["stdin"], files["stdout"], files["stderr"]
+
+	if err := ServeMCP(); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.ReadFile(files["stdout"].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := decodeResponses(t, output)
+	var ids []string
+	for _, response := range responses {
+		ids = append(ids, string(response.ID))
+	}
+	if got, want := strings.Join(ids, ","), "1,2,3,4,5,6,7,null"; got != want {
+		t.Errorf("response ids = %s, want %s", got, want)
+	}
+}
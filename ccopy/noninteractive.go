@@ -0,0 +1,147 @@
+package ccopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"codecopy/bundle"
+	"codecopy/constants"
+	"codecopy/helpers"
+	"codecopy/picker"
+	"codecopy/ui"
+)
+
+// Over-budget policies selected with --over-budget.
+const (
+	policyPick = "pick"
+	policyFail = "fail"
+	policyFit  = "fit"
+)
+
+// ExitError is an error that ends the command with a specific exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code the command ends with for err.
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
+// isNonInteractive reports whether the run must not prompt: with
+// --non-interactive, or when standard input is not a terminal.
+func isNonInteractive(args []string) bool {
+	return helpers.ContainsFlag(args, "--non-interactive") || !helpers.IsTerminal(os.Stdin)
+}
+
+// overBudgetPolicy returns what to do when the code context exceeds the
+// token limit: reopen the picker, which is the interactive default, fail,
+// which is the non-interactive default, or fit the selection automatically.
+func overBudgetPolicy(args []string, nonInteractive bool) (string, error) {
+	policy, ok := helpers.GetFlagValue(args, "--over-budget")
+	switch {
+	case !ok && nonInteractive:
+		return policyFail, nil
+	case !ok:
+		return policyPick, nil
+	case policy == policyPick && nonInteractive:
+		return "", fmt.Errorf("--over-budget pick needs an interactive terminal: use fail or fit instead")
+	case policy == policyPick || policy == policyFail || policy == policyFit:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown --over-budget policy %q: use pick, fail or fit", policy)
+}
+
+// fitToBudget brings an over-budget bundle under budget without asking: the
+// largest files are reduced to outlines while that saves tokens, then the
+// largest remaining files are dropped.
+func fitToBudget(ctx context.Context, selection *bundle.Selection, b *bundle.Bundle, budget int) (*bundle.Bundle, error) {
+	largest := b.Largest()
+
+	count := counter(ctx, selection)
+	total := b.TotalTokens
+	tokens := make(map[string]int, len(largest))
+	for _, file := range largest {
+		tokens[file.AbsPath] = file.Tokens
+	}
+
+	var outlined []bundle.File
+	for _, file := range largest {
+		if total <= budget {
+			break
+		}
+		if selection.Settings.Outlines[file.AbsPath] {
+			continue
+		}
+		outline, err := count(file.AbsPath, picker.Outline)
+		if err != nil || outline >= tokens[file.AbsPath] {
+			continue
+		}
+		selection.Settings.Outlines[file.AbsPath], selection.Settings.Truncated[file.AbsPath] = true, false
+		total -= tokens[file.AbsPath] - outline
+		tokens[file.AbsPath] = outline
+		outlined = append(outlined, file)
+	}
+
+	dropped := make(map[string]bool)
+	for {
+		for _, file := range largest {
+			if total <= budget {
+				break
+			}
+			if !dropped[file.AbsPath] {
+				dropped[file.AbsPath] = true
+				total -= tokens[file.AbsPath]
+			}
+		}
+
+		var kept []string
+		for _, file := range b.Files {
+			if !dropped[file.AbsPath] {
+				kept = append(kept, file.AbsPath)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, &ExitError{Code: constants.ExitOverBudget, Err: fmt.Errorf("no selection of files fits within %d tokens", budget)}
+		}
+
+		selection.Files = kept
+		fitted, err := selection.Generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Shared headers are counted once for the whole context, so the
+		// estimate can fall short; drop more files until the result fits.
+		if fitted.TotalTokens <= budget {
+			var outlinedPaths, droppedPaths []string
+			for _, file := range outlined {
+				if !dropped[file.AbsPath] {
+					outlinedPaths = append(outlinedPaths, file.Path)
+				}
+			}
+			for _, file := range b.Files {
+				if dropped[file.AbsPath] {
+					droppedPaths = append(droppedPaths, file.Path)
+				}
+			}
+			ui.DisplayFitted(outlinedPaths, droppedPaths, fitted.TotalTokens, budget)
+			return fitted, nil
+		}
+		total = fitted.TotalTokens
+	}
+}
@@ -0,0 +1,221 @@
+package ccopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"codecopy/bundle"
+	"codecopy/config"
+	"codecopy/helpers"
+	"codecopy/selections"
+	"codecopy/ui"
+)
+
+// selectionFlags choose which files are copied rather than how they are
+// included, so they are not saved with a selection.
+var selectionFlags = map[string]bool{
+	"-m":             true,
+	"--files":        true,
+	"--stdin":        true,
+	"--symbol":       true,
+	"--grep":         true,
+	"--grep-context": true,
+	"--last":         true,
+	"--set":          true,
+}
+
+// runFlags override safety checks or decide where this run's output goes and
+// how it ends, so they apply to one run only and are not saved either.
+var runFlags = map[string]bool{
+	"--allow-sensitive": true,
+	"--no-redact":       true,
+	"--redact-strict":   true,
+	"--strict":          true,
+	"--output":          true,
+	"--over-budget":     true,
+	"--non-interactive": true,
+	"--help":            true,
+}
+
+// openSelections opens the repository's saved selections and finds the one
+// the run recalls, if any, returning args with the flags saved with it
+// appended. Flags given now come first, so they override the saved ones.
+func openSelections(rootDir string, args []string) (*selections.Store, string, *selections.Selection, []string, error) {
+	store, err := selections.Open(rootDir)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	setName, naming := helpers.GetFlagValue(args, "--set")
+	if naming && setName == "" {
+		return nil, "", nil, nil, errors.New("--set requires a name")
+	}
+	recalled, err := recallSelection(store, args, setName)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	if recalled != nil {
+		args = append(append([]string{}, args...), savedArgs(recalled.Args)...)
+	}
+	return store, setName, recalled, args, nil
+}
+
+// recallSelection returns the saved selection the run reuses: the last run's
+// with --last, or the named set with --set when it exists and no other flag
+// selects files. It returns nil when the run makes a new selection.
+func recallSelection(store *selections.Store, args []string, setName string) (*selections.Selection, error) {
+	explicit := helpers.ContainsFlag(args, "--stdin")
+	for _, flag := range []string{"--files", "--symbol", "--grep"} {
+		if _, ok := helpers.GetFlagValue(args, flag); ok {
+			explicit = true
+		}
+	}
+
+	if helpers.ContainsFlag(args, "--last") {
+		if explicit {
+			return nil, errors.New("--last cannot be combined with --files, --stdin, --symbol or --grep")
+		}
+		if store.Last == nil {
+			return nil, errors.New("no previous selection is saved for this repository")
+		}
+		return store.Last, nil
+	}
+
+	if selection, ok := store.Sets[setName]; ok && !explicit {
+		return selection, nil
+	}
+	return nil, nil
+}
+
+// recallFiles returns the absolute paths of the recalled files that still
+// exist, with those saved as outlines and as truncated content, and reports
+// the dropped ones.
+func recallFiles(rootDir string, selection *selections.Selection) (files, outline, truncate []string, err error) {
+	existing, missing := selection.Existing(rootDir)
+	ui.DisplayDroppedFiles(missing)
+	if len(existing) == 0 {
+		return nil, nil, nil, errors.New("none of the files in the saved selection exist anymore")
+	}
+
+	files = make([]string, 0, len(existing))
+	for _, file := range existing {
+		path := filepath.Join(rootDir, filepath.FromSlash(file.Path))
+		switch file.Mode {
+		case "outline":
+			outline = append(outline, path)
+		case "truncated":
+			truncate = append(truncate, path)
+		}
+		files = append(files, path)
+	}
+	return files, outline, truncate, nil
+}
+
+// saveSelection records the copied files and the flags that shaped their
+// content as the repository's last selection and, when setName is given,
+// under that name.
+func saveSelection(store *selections.Store, setName string, selection *bundle.Selection, b *bundle.Bundle, args []string) error {
+	saved := &selections.Selection{Saved: time.Now()}
+	for _, file := range b.Files {
+		savedFile := selections.File{Path: file.Path}
+		switch {
+		case selection.Settings.Outlines[file.AbsPath]:
+			savedFile.Mode = "outline"
+		case selection.Settings.Truncated[file.AbsPath]:
+			savedFile.Mode = "truncated"
+		}
+		saved.Files = append(saved.Files, savedFile)
+	}
+
+	saved.Args = savedArgs(args)
+
+	store.Last = saved
+	if setName != "" {
+		store.Sets[setName] = saved
+	}
+	return store.Save()
+}
+
+// savedArgs returns the flags of args that shape the content of the copied
+// files, leaving out selection and per-run flags. Each flag is kept once with
+// its values; a recalled run lists the flags given now before the saved ones,
+// so the first occurrence wins.
+func savedArgs(args []string) []string {
+	var saved []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(args); i++ {
+		start := i
+		for i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+		}
+		flag, _, _ := strings.Cut(args[start], "=")
+		if selectionFlags[flag] || runFlags[flag] || seen[flag] {
+			continue
+		}
+		seen[flag] = true
+		saved = append(saved, args[start:i+1]...)
+	}
+	return saved
+}
+
+// ListSets prints the selections saved for the current directory's
+// repository with what each would cost if copied now.
+func ListSets() error {
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	cfg, err := config.Load(rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	store, err := selections.Open(rootDir)
+	if err != nil {
+		return err
+	}
+
+	var summaries []ui.SelectionSummary
+	summarize := func(name string, selection *selections.Selection) error {
+		summary := ui.SelectionSummary{Name: name, Saved: selection.Saved}
+		existing, missing := selection.Existing(rootDir)
+		summary.Files, summary.Missing = len(existing), len(missing)
+
+		if len(existing) > 0 {
+			opts, err := newOptions(rootDir, selection.Args, cfg)
+			if err != nil {
+				return fmt.Errorf("invalid flags saved with %s: %v", name, err)
+			}
+			if opts.Files, opts.Outline, opts.Truncate, err = recallFiles(rootDir, &selections.Selection{Files: existing}); err != nil {
+				return err
+			}
+			b, err := bundle.Build(context.Background(), opts)
+			if err != nil {
+				return fmt.Errorf("failed to generate code context for %s: %v", name, err)
+			}
+			summary.Tokens = b.TotalTokens
+		}
+
+		summaries = append(summaries, summary)
+		return nil
+	}
+
+	if store.Last != nil {
+		if err := summarize("(last)", store.Last); err != nil {
+			return err
+		}
+	}
+	for _, name := range store.Names() {
+		if err := summarize(name, store.Sets[name]); err != nil {
+			return err
+		}
+	}
+
+	ui.DisplaySelections(summaries)
+	return nil
+}
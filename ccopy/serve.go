@@ -0,0 +1,212 @@
+package ccopy
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"codecopy/bundle"
+	"codecopy/constants"
+	"codecopy/helpers"
+	"codecopy/ui"
+)
+
+// maxRequestBytes limits the size of a request body.
+const maxRequestBytes = 1 << 20
+
+// apiServer serves the code context of a repository over HTTP.
+type apiServer struct {
+	*workspace
+	token string
+}
+
+// fileEntry is a file and its token count in a GET /files response.
+type fileEntry struct {
+	Path   string `json:"path"`
+	Tokens int    `json:"tokens"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Serve exposes the code context of the current directory over HTTP on
+// localhost until interrupted. Requests must carry the access token, taken
+// from --token or CODECOPY_TOKEN or generated, as a bearer token.
+func Serve(args []string) error {
+	ws, err := newWorkspace()
+	if err != nil {
+		return err
+	}
+
+	port := constants.ServePort
+	if _, ok := helpers.GetFlagValue(args, "--port"); ok {
+		if port, err = helpers.GetIntFlag(args, "--port"); err != nil {
+			return err
+		}
+	}
+
+	token, _ := helpers.GetFlagValue(args, "--token")
+	if token == "" {
+		token = os.Getenv("CODECOPY_TOKEN")
+	}
+	generated := token == ""
+	if generated {
+		secret := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("failed to generate access token: %v", err)
+		}
+		token = hex.EncodeToString(secret)
+	}
+
+	s := &apiServer{workspace: ws, token: token}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+
+	ui.DisplayServing("http://"+listener.Addr().String(), token, generated)
+	server := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	return server.Serve(listener)
+}
+
+// handler routes the API endpoints behind token authentication.
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files", s.handleFiles)
+	mux.HandleFunc("GET /tree", s.handleTree)
+	mux.HandleFunc("POST /bundle", s.handleBundle)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the access token in an
+// "Authorization: Bearer" header.
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid access token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleFiles lists the repository's files with the tokens each would take
+// in full, optionally filtered by "glob" query parameters.
+func (s *apiServer) handleFiles(w http.ResponseWriter, r *http.Request) {
+	files, counts, errs, err := s.countFiles(listing(r.URL.Query()["glob"], true))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	entries := []fileEntry{}
+	total := 0
+	for _, file := range files {
+		entry := fileEntry{Path: helpers.RelativePath(s.rootDir, file), Tokens: counts[file]}
+		if err := errs[file]; err != nil {
+			entry.Error = err.Error()
+		}
+		total += entry.Tokens
+		entries = append(entries, entry)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"files": entries, "total_tokens": total})
+}
+
+// handleTree returns the repository's directory tree with token counts as
+// text, optionally filtered by "glob" query parameters.
+func (s *apiServer) handleTree(w http.ResponseWriter, r *http.Request) {
+	files, counts, _, err := s.countFiles(listing(r.URL.Query()["glob"], true))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tree := helpers.BuildTreeWithTokenCounts(s.rootDir, files, counts, nil)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(strings.Join(tree, "\n") + "\n"))
+}
+
+// handleBundle builds a code context from the files and settings in the
+// request body and returns it in the requested format.
+func (s *apiServer) handleBundle(w http.ResponseWriter, r *http.Request) {
+	var request bundleRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	b, err := s.build(request)
+	var requestErr *requestError
+	var budgetErr *bundle.BudgetError
+	var diagnostics bundle.Diagnostics
+	switch {
+	case errors.As(err, &budgetErr):
+		counts := make(map[string]int, len(b.Files))
+		for _, file := range b.Files {
+			counts[file.Path] = file.Tokens
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":        budgetErr.Error(),
+			"total_tokens": budgetErr.Tokens,
+			"budget":       budgetErr.Budget,
+			"files":        counts,
+		})
+		return
+	case errors.As(err, &diagnostics):
+		problems := make([]map[string]string, 0, len(diagnostics))
+		for _, problem := range diagnostics {
+			problems = append(problems, map[string]string{"path": problem.Path, "kind": string(problem.Kind), "message": problem.Err.Error()})
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error(), "diagnostics": problems})
+		return
+	case errors.As(err, &requestErr):
+		writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	format, _ := bundle.ParseFormat(request.Format)
+	body, err := b.Render(format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	switch format {
+	case bundle.FormatMarkdown:
+		contentType = "text/markdown; charset=utf-8"
+	case bundle.FormatJSON:
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Total-Tokens", strconv.Itoa(b.TotalTokens))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+}
+
+// writeJSON writes value as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error as a JSON response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,397 @@
+package ccopy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codecopy/config"
+	"codecopy/helpers"
+)
+
+const testToken = "secret"
+
+// newTestWorkspace creates a repository with the given files, relative to its
+// root, and a workspace for it that ignores the user's configuration.
+func newTestWorkspace(t *testing.T, files map[string]string) *workspace {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	rootDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := config.Load(rootDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &workspace{rootDir: rootDir, cfg: cfg}
+}
+
+var testFiles = map[string]string{
+	"go.mod":      "module example\n\ngo 1.22\n",
+	"main.go":     "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+	"pkg/util.go": "package pkg\n\n// Add adds two numbers.\nfunc Add(a, b int) int {\n\treturn a + b\n}\n",
+}
+
+func newTestServer(t *testing.T, files map[string]string) http.Handler {
+	t.Helper()
+	s := &apiServer{workspace: newTestWorkspace(t, files), token: testToken}
+	return s.handler()
+}
+
+func serveRequest(handler http.Handler, method, target, body, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decodeBody(t *testing.T, recorder *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, recorder.Body)
+	}
+	return body
+}
+
+func TestServeAuthentication(t *testing.T) {
+	handler := newTestServer(t, testFiles)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + testToken, http.StatusUnauthorized},
+		{"valid", "Bearer " + testToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/files", nil)
+			if tt.header != "" {
+				request.Header.Set("Authorization", tt.header)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized {
+				if got := recorder.Header().Get("WWW-Authenticate"); got != "Bearer" {
+					t.Errorf("WWW-Authenticate = %q, want Bearer", got)
+				}
+				if strings.Contains(recorder.Body.String(), "main.go") {
+					t.Errorf("rejected request received files: %s", recorder.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestServeFiles(t *testing.T) {
+	handler := newTestServer(t, testFiles)
+
+	recorder := serveRequest(handler, http.MethodGet, "/files", "", testToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+	}
+	var response struct {
+		Files       []fileEntry `json:"files"`
+		TotalTokens int         `json:"total_tokens"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	paths := make(map[string]bool)
+	for _, file := range response.Files {
+		paths[file.Path] = true
+		if file.Tokens <= 0 {
+			t.Errorf("%s has %d tokens", file.Path, file.Tokens)
+		}
+		total += file.Tokens
+	}
+	for name := range testFiles {
+		if !paths[name] {
+			t.Errorf("missing %s in %v", name, paths)
+		}
+	}
+	if response.TotalTokens != total {
+		t.Errorf("total_tokens = %d, want the sum %d", response.TotalTokens, total)
+	}
+
+	recorder = serveRequest(handler, http.MethodGet, "/files?glob=pkg/**", "", testToken)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Files) != 1 || response.Files[0].Path != "pkg/util.go" {
+		t.Errorf("glob pkg/** matched %+v, want only pkg/util.go", response.Files)
+	}
+}
+
+func TestServeTree(t *testing.T) {
+	handler := newTestServer(t, testFiles)
+
+	recorder := serveRequest(handler, http.MethodGet, "/tree", "", testToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+	}
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	for _, name := range []string{"main.go", "pkg", "util.go"} {
+		if !strings.Contains(recorder.Body.String(), name) {
+			t.Errorf("tree is missing %s:\n%s", name, recorder.Body)
+		}
+	}
+}
+
+func TestServeBundle(t *testing.T) {
+	handler := newTestServer(t, testFiles)
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		contains    []string
+		excludes    []string
+	}{
+		{
+			name:        "paths",
+			body:        `{"paths": ["main.go"]}`,
+			contentType: "text/plain",
+			contains:    []string{"main.go", `println("hello")`},
+			excludes:    []string{"func Add"},
+		},
+		{
+			name:        "globs",
+			body:        `{"globs": ["pkg/*.go"]}`,
+			contentType: "text/plain",
+			contains:    []string{"func Add"},
+			excludes:    []string{`println("hello")`},
+		},
+		{
+			name:        "markdown",
+			body:        `{"paths": ["pkg"], "format": "markdown"}`,
+			contentType: "text/markdown",
+			contains:    []string{"## pkg/util.go", "```go"},
+		},
+		{
+			name:        "json",
+			body:        `{"paths": ["main.go"], "format": "json"}`,
+			contentType: "application/json",
+			contains:    []string{`"path": "main.go"`, `"total_tokens"`},
+		},
+		{
+			name:        "minify",
+			body:        `{"paths": ["pkg/util.go"], "compression": {"minify": true}}`,
+			contentType: "text/plain",
+			contains:    []string{"func Add"},
+			excludes:    []string{"// Add adds"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveRequest(handler, http.MethodPost, "/bundle", tt.body, testToken)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+			}
+			if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.contentType)
+			}
+			if recorder.Header().Get("X-Total-Tokens") == "" {
+				t.Error("missing X-Total-Tokens header")
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("bundle is missing %q:\n%s", want, recorder.Body)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(recorder.Body.String(), unwanted) {
+					t.Errorf("bundle contains %q:\n%s", unwanted, recorder.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestServeBundleRejections(t *testing.T) {
+	files := map[string]string{"bad.txt": "hello \xff world\n"}
+	for name, content := range testFiles {
+		files[name] = content
+	}
+	handler := newTestServer(t, files)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string
+	}{
+		{"over budget", `{"paths": ["main.go", "pkg"], "budget": 1}`, http.StatusUnprocessableEntity, "total_tokens"},
+		{"strict with unreadable file", `{"paths": ["main.go", "bad.txt"], "strict": true}`, http.StatusUnprocessableEntity, "diagnostics"},
+		{"parent path", `{"paths": ["../outside.go"]}`, http.StatusBadRequest, "error"},
+		{"nested parent path", `{"paths": ["pkg/../../outside.go"]}`, http.StatusBadRequest, "error"},
+		{"absolute path", `{"paths": ["/etc/passwd"]}`, http.StatusBadRequest, "error"},
+		{"parent path in outline", `{"paths": ["main.go"], "compression": {"outline": ["../x.go"]}}`, http.StatusBadRequest, "error"},
+		{"unknown field", `{"files": ["main.go"]}`, http.StatusBadRequest, "error"},
+		{"unknown format", `{"paths": ["main.go"], "format": "html"}`, http.StatusBadRequest, "error"},
+		{"request too large", `{"paths": ["` + strings.Repeat("a", maxRequestBytes) + `"]}`, http.StatusBadRequest, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveRequest(handler, http.MethodPost, "/bundle", tt.body, testToken)
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+			if body := decodeBody(t, recorder); body[tt.field] == nil {
+				t.Errorf("response has no %q: %v", tt.field, body)
+			}
+		})
+	}
+
+	t.Run("strict diagnostics", func(t *testing.T) {
+		recorder := serveRequest(handler, http.MethodPost, "/bundle", `{"paths": ["bad.txt"], "strict": true}`, testToken)
+		var response struct {
+			Diagnostics []map[string]string `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Diagnostics) != 1 || response.Diagnostics[0]["path"] != "bad.txt" || response.Diagnostics[0]["kind"] != "invalid_utf8" {
+			t.Errorf("diagnostics = %v, want bad.txt as invalid_utf8", response.Diagnostics)
+		}
+	})
+
+	t.Run("lenient without strict", func(t *testing.T) {
+		recorder := serveRequest(handler, http.MethodPost, "/bundle", `{"paths": ["main.go", "bad.txt"]}`, testToken)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+		}
+	})
+
+	t.Run("budget details", func(t *testing.T) {
+		recorder := serveRequest(handler, http.MethodPost, "/bundle", `{"paths": ["main.go"], "budget": 1}`, testToken)
+		body := decodeBody(t, recorder)
+		if body["budget"] != float64(1) {
+			t.Errorf("budget = %v, want 1", body["budget"])
+		}
+		files, _ := body["files"].(map[string]any)
+		if _, ok := files["main.go"]; !ok {
+			t.Errorf("files = %v, want main.go with its token count", body["files"])
+		}
+	})
+}
+
+func TestServeSymlinksOutsideRoot(t *testing.T) {
+	ws := newTestWorkspace(t, testFiles)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.go"), []byte("package outside\n\nconst leaked = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"link.go":   filepath.Join(outside, "secret.go"),
+		"linked":    outside,
+		"inside.go": filepath.Join(ws.rootDir, "main.go"),
+	} {
+		if err := os.Symlink(target, filepath.Join(ws.rootDir, link)); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+	}
+	handler := (&apiServer{workspace: ws, token: testToken}).handler()
+
+	for _, path := range []string{"link.go", "linked", "linked/secret.go"} {
+		t.Run(path, func(t *testing.T) {
+			recorder := serveRequest(handler, http.MethodPost, "/bundle", `{"paths": ["`+path+`"]}`, testToken)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusBadRequest, recorder.Body)
+			}
+		})
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		recorder := serveRequest(handler, http.MethodPost, "/bundle", `{"paths": ["."]}`, testToken)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body)
+		}
+		if strings.Contains(recorder.Body.String(), "leaked") {
+			t.Errorf("bundle includes a file outside the repository:\n%s", recorder.Body)
+		}
+		if !strings.Contains(recorder.Body.String(), "inside.go") {
+			t.Errorf("bundle is missing the symlink within the repository:\n%s", recorder.Body)
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		recorder := serveRequest(handler, http.MethodGet, "/files", "", testToken)
+		if strings.Contains(recorder.Body.String(), "link.go") {
+			t.Errorf("files lists a symlink outside the repository: %s", recorder.Body)
+		}
+	})
+}
+
+func TestCountFiles(t *testing.T) {
+	header := "// Copyright 2024 Example Corp. Licensed under the Apache License, Version 2.0.\n// You may not use this file except in compliance with the License.\n\n"
+	files := map[string]string{
+		"go.mod":   "module example\n",
+		"a.go":     header + "package p\n\nfunc A() {}\n",
+		"b.go":     header + "package p\n\nfunc B() {}\n",
+		"c.go":     header + "package p\n\nfunc C() {}\n",
+		"bad.txt":  "hello \xff world\n",
+		"data.bin": "\x00\x01\x02",
+	}
+	ws := newTestWorkspace(t, files)
+
+	listed, counts, errs, err := ws.countFiles(listing(nil, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != len(files) {
+		t.Errorf("listed %d files, want %d", len(listed), len(files))
+	}
+	for _, name := range []string{"go.mod", "a.go", "b.go", "c.go"} {
+		path := filepath.Join(ws.rootDir, name)
+		want, err := helpers.CountTokens(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts[path] != want {
+			t.Errorf("%s has %d tokens, want %d counted in full with its header", name, counts[path], want)
+		}
+	}
+	for _, name := range []string{"bad.txt", "data.bin"} {
+		if errs[filepath.Join(ws.rootDir, name)] == nil {
+			t.Errorf("%s has no error", name)
+		}
+	}
+}
+
+func TestServeMethodNotAllowed(t *testing.T) {
+	handler := newTestServer(t, testFiles)
+
+	if recorder := serveRequest(handler, http.MethodGet, "/bundle", "", testToken); recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /bundle status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,312 @@
+package ccopy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"codecopy/bundle"
+	"codecopy/config"
+	"codecopy/constants"
+	"codecopy/helpers"
+	"codecopy/selections"
+	"codecopy/ui"
+)
+
+// fallbackOutput is the file the code context is written to when the
+// clipboard is unavailable.
+const fallbackOutput = "code_context.txt"
+
+// watchSession is the state of a running watch.
+type watchSession struct {
+	rootDir    string
+	args       []string
+	opts       bundle.Options
+	recalled   *selections.Selection
+	outputPath string
+
+	// fixed is set for a selection made with -m or --stdin, which is kept
+	// in selection instead of being made again on every change.
+	fixed     bool
+	selection *bundle.Selection
+
+	bundle   *bundle.Bundle
+	included map[string]bool
+	watched  map[string]bool
+}
+
+// Watch copies the code context like Run, then regenerates it whenever an
+// included file changes or, unless files were picked with -m or --stdin, any
+// file in the tree does, keeping the clipboard or the --output file current
+// until interrupted.
+func Watch(args []string) error {
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	cfg, err := config.Load(rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	_, _, recalled, args, err := openSelections(rootDir, args)
+	if err != nil {
+		return err
+	}
+
+	opts, err := newOptions(rootDir, args, cfg)
+	if err != nil {
+		return err
+	}
+
+	s := &watchSession{
+		rootDir:  rootDir,
+		args:     args,
+		opts:     opts,
+		recalled: recalled,
+		fixed:    helpers.ContainsFlag(args, "-m") || helpers.ContainsFlag(args, "--stdin"),
+		watched:  make(map[string]bool),
+	}
+	if outputPath, ok := helpers.GetFlagValue(args, "--output"); ok {
+		if outputPath == "" {
+			return fmt.Errorf("--output requires a file name")
+		}
+		if s.outputPath, err = filepath.Abs(outputPath); err != nil {
+			return err
+		}
+	}
+
+	b, err := s.generate()
+	if err != nil {
+		return err
+	}
+	ui.DisplayDeniedFiles(b.Denied)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch files: %v", err)
+	}
+	defer watcher.Close()
+
+	destination, err := s.deliver(b)
+	if err != nil {
+		return err
+	}
+	s.watchTree(watcher, rootDir)
+	ui.DisplayDiagnostics(b.Diagnostics)
+	ui.DisplayWatching(len(b.Files), b.TotalTokens, destination)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	debounce := time.NewTimer(constants.WatchDebounce)
+	debounce.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			s.track(watcher, event)
+			if s.relevant(event) {
+				debounce.Reset(constants.WatchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("Warning: file watcher error: %v\n", err)
+		case <-debounce.C:
+			s.update()
+		case <-interrupt:
+			fmt.Println()
+			return nil
+		}
+	}
+}
+
+// generate selects the files and generates their code context. A selection
+// made with -m or --stdin is kept from the first run.
+func (s *watchSession) generate() (*bundle.Bundle, error) {
+	ctx := context.Background()
+	selection := s.selection
+	if selection == nil {
+		var err error
+		if selection, err = selectFiles(ctx, s.opts, s.args, s.recalled); err != nil {
+			return nil, err
+		}
+		if s.fixed {
+			s.selection = selection
+		}
+	}
+
+	b, err := selection.Generate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate code context: %v", err)
+	}
+	if len(b.Diagnostics) > 0 && helpers.ContainsFlag(s.args, "--strict") {
+		return nil, fmt.Errorf("refusing to copy: %v", b.Diagnostics)
+	}
+	if len(b.Redactions) > 0 && helpers.ContainsFlag(s.args, "--redact-strict") {
+		return nil, fmt.Errorf("refusing to copy: secrets were detected in %d file(s) and --redact-strict is set", len(b.Redactions))
+	}
+
+	s.bundle = b
+	s.included = make(map[string]bool, len(b.Files))
+	for _, file := range b.Files {
+		s.included[file.AbsPath] = true
+	}
+	return b, nil
+}
+
+// deliver copies the code context to the clipboard or writes it to the
+// output file, and returns where it went.
+func (s *watchSession) deliver(b *bundle.Bundle) (string, error) {
+	if s.outputPath != "" {
+		if err := helpers.WriteToFile(b.Text, s.outputPath); err != nil {
+			return "", err
+		}
+		return s.outputPath, nil
+	}
+	if err := helpers.CopyToClipboard(b.Text); err == nil {
+		return "clipboard", nil
+	}
+	if err := helpers.WriteToFile(b.Text, filepath.Join(s.rootDir, fallbackOutput)); err != nil {
+		return "", err
+	}
+	return fallbackOutput + " (clipboard unavailable)", nil
+}
+
+// watchTree adds dir and every directory below it, except ignored ones such
+// as node_modules, to the watcher, so files created anywhere in the tree are
+// noticed as well as changes to included ones.
+func (s *watchSession) watchTree(watcher *fsnotify.Watcher, dir string) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != s.rootDir && helpers.IsIgnoredDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		if s.watched[path] {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			fmt.Printf("Warning: failed to watch %s: %v\n", path, err)
+			return nil
+		}
+		s.watched[path] = true
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to watch %s: %v\n", dir, err)
+	}
+}
+
+// track keeps the watched directories in step with the tree: directories
+// created after the watch started are added, and removed ones forgotten so
+// they are added again if they reappear.
+func (s *watchSession) track(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	switch {
+	case event.Has(fsnotify.Create):
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			s.watchTree(watcher, event.Name)
+		}
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		for dir := range s.watched {
+			if dir == event.Name || strings.HasPrefix(dir, event.Name+string(filepath.Separator)) {
+				delete(s.watched, dir)
+			}
+		}
+	}
+}
+
+// relevant reports whether an event may change the code context: a change to
+// an included file or, unless the selection is fixed, to any file a fresh
+// selection could pick up, since a new file, an edit that makes a file match
+// --grep or a changed manifest can each change what is selected.
+func (s *watchSession) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if event.Name == s.outputPath || event.Name == filepath.Join(s.rootDir, fallbackOutput) {
+		return false
+	}
+	if s.included[event.Name] {
+		return true
+	}
+	return !s.fixed && s.candidate(event.Name)
+}
+
+// candidate reports whether a fresh selection could include path: it lies in
+// a watched directory and is not itself an ignored directory.
+func (s *watchSession) candidate(path string) bool {
+	if !s.watched[filepath.Dir(path)] {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err != nil || !info.IsDir() || !helpers.IsIgnoredDir(info.Name())
+}
+
+// update regenerates the code context after a change and reports how the
+// token totals moved. Nothing is reported when the content is unchanged.
+func (s *watchSession) update() {
+	previous := s.bundle
+	b, err := s.generate()
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	if b.Text == previous.Text {
+		return
+	}
+
+	destination, err := s.deliver(b)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	ui.DisplayDiagnostics(b.Diagnostics)
+	ui.DisplayWatchUpdate(previous.TotalTokens, b.TotalTokens, tokenChanges(previous, b), destination)
+}
+
+// tokenChanges lists the files whose token counts differ between two
+// bundles, including files added to or removed from the selection.
+func tokenChanges(before, after *bundle.Bundle) []ui.TokenChange {
+	var changes []ui.TokenChange
+
+	beforeTokens := make(map[string]int, len(before.Files))
+	for _, file := range before.Files {
+		beforeTokens[file.Path] = file.Tokens
+	}
+	afterTokens := make(map[string]int, len(after.Files))
+	for _, file := range after.Files {
+		afterTokens[file.Path] = file.Tokens
+		tokens, existed := beforeTokens[file.Path]
+		if !existed {
+			changes = append(changes, ui.TokenChange{Path: file.Path, After: file.Tokens, Added: true})
+		} else if tokens != file.Tokens {
+			changes = append(changes, ui.TokenChange{Path: file.Path, Before: tokens, After: file.Tokens})
+		}
+	}
+	for _, file := range before.Files {
+		if _, kept := afterTokens[file.Path]; !kept {
+			changes = append(changes, ui.TokenChange{Path: file.Path, Before: file.Tokens, Removed: true})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
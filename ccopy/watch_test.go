@@ -0,0 +1,51 @@
+package ccopy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatchRelevant(t *testing.T) {
+	rootDir := t.TempDir()
+	for _, dir := range []string{"pkg", "node_modules"} {
+		if err := os.Mkdir(filepath.Join(rootDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(rootDir, filepath.FromSlash(name)) }
+
+	tests := []struct {
+		name     string
+		event    fsnotify.Event
+		fixed    bool
+		relevant bool
+	}{
+		{"write to an included file", fsnotify.Event{Name: path("main.go"), Op: fsnotify.Write}, false, true},
+		{"write to an included file of a fixed selection", fsnotify.Event{Name: path("main.go"), Op: fsnotify.Write}, true, true},
+		{"write to another file", fsnotify.Event{Name: path("pkg/util.go"), Op: fsnotify.Write}, false, true},
+		{"write to another file of a fixed selection", fsnotify.Event{Name: path("pkg/util.go"), Op: fsnotify.Write}, true, false},
+		{"new file", fsnotify.Event{Name: path("pkg/new.go"), Op: fsnotify.Create}, false, true},
+		{"removed manifest", fsnotify.Event{Name: path("go.mod"), Op: fsnotify.Remove}, false, true},
+		{"new ignored directory", fsnotify.Event{Name: path("node_modules"), Op: fsnotify.Create}, false, false},
+		{"file in an unwatched directory", fsnotify.Event{Name: path("node_modules/x.js"), Op: fsnotify.Write}, false, false},
+		{"chmod", fsnotify.Event{Name: path("main.go"), Op: fsnotify.Chmod}, false, false},
+		{"output file", fsnotify.Event{Name: path("out.md"), Op: fsnotify.Write}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &watchSession{
+				rootDir:    rootDir,
+				outputPath: path("out.md"),
+				fixed:      tt.fixed,
+				included:   map[string]bool{path("main.go"): true},
+				watched:    map[string]bool{rootDir: true, path("pkg"): true},
+			}
+			if got := s.relevant(tt.event); got != tt.relevant {
+				t.Errorf("relevant(%v) = %v, want %v", tt.event, got, tt.relevant)
+			}
+		})
+	}
+}
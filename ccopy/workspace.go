@@ -0,0 +1,163 @@
+package ccopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"codecopy/bundle"
+	"codecopy/config"
+)
+
+// workspace answers requests for the code context of one repository on
+// behalf of the HTTP and MCP servers.
+type workspace struct {
+	rootDir string
+	cfg     *config.Config
+}
+
+// newWorkspace loads the configuration of the current directory.
+func newWorkspace() (*workspace, error) {
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	cfg, err := config.Load(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	return &workspace{rootDir: rootDir, cfg: cfg}, nil
+}
+
+// bundleRequest describes a code context to build: the body of a POST
+// /bundle request or the arguments of the build_context tool.
+type bundleRequest struct {
+	// Paths are files or directories relative to the repository root, and
+	// Globs select files by their path relative to the root, as in
+	// .gitignore. Without either, the files are selected as codecopy does
+	// by default.
+	Paths []string `json:"paths"`
+	Globs []string `json:"globs"`
+	// Format is "text" (the default), "markdown" or "json".
+	Format string `json:"format"`
+	// Budget rejects bundles with more tokens; 0 means no budget.
+	Budget int `json:"budget"`
+	// Strict rejects bundles with files that could not be read or counted.
+	Strict      bool               `json:"strict"`
+	Compression compressionRequest `json:"compression"`
+}
+
+// compressionRequest mirrors the command-line flags that shrink a bundle.
+type compressionRequest struct {
+	Minify             bool `json:"minify"`
+	KeepLicense        bool `json:"keep_license"`
+	KeepDocComments    bool `json:"keep_doc_comments"`
+	KeepInlineComments bool `json:"keep_inline_comments"`
+	KeepHeaders        bool `json:"keep_headers"`
+	MaxBytes           int  `json:"max_bytes"`
+	MaxLines           int  `json:"max_lines"`
+	MaxFileTokens      int  `json:"max_file_tokens"`
+	// SummarizeOver overrides the summarization threshold; 0 disables it.
+	SummarizeOver *int `json:"summarize_over"`
+	// Outline and Truncate list files, relative to the root, reduced to their
+	// declarations or to their head and tail.
+	Outline  []string `json:"outline"`
+	Truncate []string `json:"truncate"`
+}
+
+// requestError is a problem with a request rather than with the repository.
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// build generates the code context described by the request. Problems with
+// the request are returned as *requestError. A context over the budget is
+// returned with a *bundle.BudgetError, and one with file problems in a
+// strict request with its bundle.Diagnostics.
+func (ws *workspace) build(request bundleRequest) (*bundle.Bundle, error) {
+	if _, err := bundle.ParseFormat(request.Format); err != nil {
+		return nil, &requestError{err}
+	}
+
+	compression := request.Compression
+	opts := bundle.Options{
+		Files:         request.Paths,
+		Globs:         request.Globs,
+		Outline:       compression.Outline,
+		Truncate:      compression.Truncate,
+		MaxBytes:      compression.MaxBytes,
+		MaxLines:      compression.MaxLines,
+		MaxFileTokens: compression.MaxFileTokens,
+		KeepHeaders:   compression.KeepHeaders,
+		Budget:        request.Budget,
+		Strict:        request.Strict,
+	}
+	if compression.SummarizeOver != nil {
+		opts.SummarizeOver = *compression.SummarizeOver
+		opts.DisableSummaries = *compression.SummarizeOver == 0
+	}
+	if compression.Minify {
+		opts.Minify = minifyOptions(compression.KeepLicense, compression.KeepDocComments, compression.KeepInlineComments)
+	}
+
+	b, err := bundle.Build(context.Background(), ws.confine(opts))
+	var pathErr *bundle.PathError
+	if errors.As(err, &pathErr) {
+		return nil, &requestError{err}
+	}
+	return b, err
+}
+
+// confine sets opts to build from the repository with its configuration,
+// refusing paths that lead outside it, directly or through symlinks.
+func (ws *workspace) confine(opts bundle.Options) bundle.Options {
+	opts.Root, opts.Config, opts.Confine = ws.rootDir, ws.cfg, true
+	return opts
+}
+
+// listing returns the options that select every file, or only those
+// matching globs, or, with neither, codecopy's default selection.
+func listing(globs []string, all bool) bundle.Options {
+	if len(globs) > 0 {
+		return bundle.Options{Globs: globs}
+	}
+	if all {
+		return bundle.Options{Files: []string{"."}}
+	}
+	return bundle.Options{}
+}
+
+// countFiles selects files as opts describes and counts the tokens each
+// would take in full in a single pass, returning the errors of files that
+// could not be counted separately.
+func (ws *workspace) countFiles(opts bundle.Options) ([]string, map[string]int, map[string]error, error) {
+	ctx := context.Background()
+	selection, err := bundle.Select(ctx, ws.confine(opts))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	selection.Settings.DedupeHeaders = false
+	b, err := selection.Generate(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	_, counts, _ := tokenCounts(b)
+	errs := make(map[string]error)
+	for _, skipped := range b.Skipped {
+		errs[skipped.AbsPath] = errors.New(skipped.Reason)
+	}
+	for _, problem := range b.Diagnostics {
+		if _, counted := counts[problem.AbsPath]; !counted {
+			errs[problem.AbsPath] = problem.Err
+		}
+	}
+	return selection.Files, counts, errs, nil
+}
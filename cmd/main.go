@@ -11,14 +11,56 @@ func main() {
 	args := os.Args[1:]
 
 	if len(args) > 0 && args[0] == "--help" {
-		ui.DisplayHelp()
+		rootDir, err := os.Getwd()
+		if err != nil {
+			ui.DisplayError(err)
+			os.Exit(1)
+		}
+		registry, err := ccopy.LoadRegistry(rootDir)
+		if err != nil {
+			ui.DisplayError(err)
+			os.Exit(1)
+		}
+		ui.DisplayHelp(registry)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "sets" {
+		if err := ccopy.ListSets(); err != nil {
+			ui.DisplayError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "mcp" {
+		if err := ccopy.ServeMCP(); err != nil {
+			ui.DisplayError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "serve" {
+		if err := ccopy.Serve(args[1:]); err != nil {
+			ui.DisplayError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "watch" {
+		if err := ccopy.Watch(args[1:]); err != nil {
+			ui.DisplayError(err)
+			os.Exit(1)
+		}
 		return
 	}
 
 	err := ccopy.Run(args)
 	if err != nil {
 		ui.DisplayError(err)
-		os.Exit(1)
+		os.Exit(ccopy.ExitCode(err))
 	}
 
 	ui.DisplayHelpInfo()
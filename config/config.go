@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codecopy/languages"
+	"codecopy/redact"
+)
+
+// FileName is the name of the per-project configuration file.
+const FileName = ".codecopy.json"
+
+// Config holds user settings loaded from the user and project configuration files.
+type Config struct {
+	// Languages extends or overrides the built-in language registry.
+	Languages []languages.Language `json:"languages,omitempty"`
+	// CommonFilenames adds build and deployment file names included for every project.
+	CommonFilenames []string `json:"common_filenames,omitempty"`
+	// Redact configures secret redaction.
+	Redact RedactConfig `json:"redact,omitempty"`
+	// Deny adds sensitive path patterns that are never included; a leading
+	// "!" re-allows a path denied by default. Re-allowing is only honored in
+	// the user configuration, so a repository cannot expose its own secrets.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// RedactConfig holds user-defined redaction rules applied in addition to the built-in detectors.
+type RedactConfig struct {
+	Rules []redact.Rule `json:"rules,omitempty"`
+}
+
+// Load reads the user configuration (codecopy/config.json in the user config
+// directory) followed by the project's .codecopy.json in rootDir. Settings from
+// the project file are applied after, and so take precedence over, the user's,
+// except that its "!" deny entries are ignored.
+func Load(rootDir string) (*Config, error) {
+	cfg := &Config{}
+
+	var paths []string
+	if userDir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(userDir, "codecopy", "config.json"))
+	}
+	projectPath := filepath.Join(rootDir, FileName)
+	paths = append(paths, projectPath)
+
+	for _, path := range paths {
+		var fileCfg Config
+		if err := readFile(path, &fileCfg); err != nil {
+			return nil, err
+		}
+		if path == projectPath {
+			fileCfg.Deny = withoutAllows(fileCfg.Deny)
+		}
+		cfg.Languages = append(cfg.Languages, fileCfg.Languages...)
+		cfg.CommonFilenames = append(cfg.CommonFilenames, fileCfg.CommonFilenames...)
+		cfg.Redact.Rules = append(cfg.Redact.Rules, fileCfg.Redact.Rules...)
+		cfg.Deny = append(cfg.Deny, fileCfg.Deny...)
+	}
+
+	return cfg, nil
+}
+
+// withoutAllows drops the "!" entries that re-allow denied paths.
+func withoutAllows(patterns []string) []string {
+	var kept []string
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "!") {
+			kept = append(kept, pattern)
+		}
+	}
+	return kept
+}
+
+// Registry returns the built-in language registry with the configured languages merged in.
+func (c *Config) Registry() *languages.Registry {
+	registry := languages.Default()
+	registry.Merge(c.Languages)
+	registry.AddCommonFilenames(c.CommonFilenames)
+	return registry
+}
+
+// readFile decodes a JSON configuration file, ignoring files that do not exist.
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return nil
+}
@@ -1,50 +1,36 @@
 package constants
 
+import "time"
+
 const (
 	TokenLimit = 10000
+	// SummarizeThreshold is the token count above which structured data files
+	// and lockfiles are replaced by a summary.
+	SummarizeThreshold = 2000
+	// DowngradeTruncateLines is the number of lines kept of a file downgraded
+	// to truncated content in the picker.
+	DowngradeTruncateLines = 100
+	// WatchDebounce is how long watch mode waits for file changes to settle
+	// before regenerating the code context.
+	WatchDebounce = 300 * time.Millisecond
+	// ServePort is the localhost port the HTTP API listens on by default.
+	ServePort = 7878
+	// MaxFileBytes is the default size above which a selected file is not
+	// read, unless it is truncated, summarized or rendered as a notebook.
+	MaxFileBytes = 10 << 20
 )
 
-var (
-	GoFiles = []string{
-		".go", ".mod", ".sum", ".toml", ".yaml", ".yml", ".json", ".md", ".txt",
-	}
-
-	PythonFiles = []string{
-		".py", ".pyc", ".pyd", ".pyo", ".pyw", ".pyz", ".pyi", ".ini", ".toml",
-		".yaml", ".yml", ".json", ".md", ".txt",
-	}
-
-	JavaScriptFiles = []string{
-		".js", ".mjs", ".cjs", ".ts", ".tsx", ".jsx", ".es6", ".es", ".json",
-		".jsonc", ".json5", ".css", ".scss", ".sass", ".less", ".styl", ".html",
-		".htm", ".xhtml", ".vue", ".svelte", ".angular", ".yaml", ".yml", ".toml",
-		".ini", ".md", ".txt",
-	}
-
-	RustFiles = []string{
-		".rs", ".toml", ".lock", ".yaml", ".yml", ".json", ".md", ".txt",
-	}
-
-	PHPFiles = []string{
-		".php", ".phtml", ".php3", ".php4", ".php5", ".php7", ".phps", ".ini",
-		".json", ".xml", ".yaml", ".yml", ".toml", ".md", ".txt",
-	}
-
-	JavaFiles = []string{
-		".java", ".class", ".jar", ".xml", ".json", ".yaml", ".yml", ".toml",
-		".md", ".txt",
-	}
-
-	RubyFiles = []string{
-		".rb", ".rbw", ".rake", ".gemspec", ".ru", ".erb", ".yml", ".yaml",
-		".json", ".toml", ".md", ".txt",
-	}
-
-	CSharpFiles = []string{
-		".cs", ".csx", ".sln", ".csproj", ".vbproj", ".xml", ".json", ".yaml",
-		".yml", ".toml", ".md", ".txt",
-	}
+// Exit codes of a run. In non-interactive mode the code context is still
+// delivered when files could not be read or counted, secrets are redacted or
+// the clipboard fails; the exit code tells scripts it is incomplete.
+const (
+	ExitOverBudget = 3
+	ExitUnreadable = 4
+	ExitRedacted   = 5
+	ExitClipboard  = 6
+)
 
+var (
 	IgnoredDirs = []string{
 		"node_modules", ".git", ".vscode", ".idea", ".nextjs", "__pycache__", "venv", "vendor",
 		"build", "dist", "bin", "obj", "target", "debug", "release", "tmp", "temp",
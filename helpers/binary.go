@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLen is the number of leading bytes inspected for NUL bytes and MIME type.
+const sniffLen = 8000
+
+// InvalidUTF8 is the reason given for content that looks like text but is not
+// valid UTF-8.
+const InvalidUTF8 = "invalid UTF-8"
+
+// BinaryFileError is returned by ReadFileContent when a file does not contain text.
+type BinaryFileError struct {
+	Path   string
+	Size   int64
+	MIME   string
+	Reason string
+}
+
+func (e *BinaryFileError) Error() string {
+	return fmt.Sprintf("%s is not a text file: %s", e.Path, e.Reason)
+}
+
+// Placeholder returns a short description of the binary file that can stand in
+// for its content.
+func (e *BinaryFileError) Placeholder() string {
+	return fmt.Sprintf("[binary file omitted: %s, %s]", FormatSize(e.Size), e.MIME)
+}
+
+// SniffBinary inspects file content and returns its detected MIME type and, if
+// the content is not text, the reason it was classified as binary.
+func SniffBinary(content []byte) (mime string, reason string) {
+	head := content
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+
+	mime = http.DetectContentType(head)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return mime, "contains NUL bytes"
+	}
+	if !isTextMIME(mime) {
+		return mime, fmt.Sprintf("detected as %s", mime)
+	}
+	if !utf8.Valid(content) {
+		return mime, InvalidUTF8
+	}
+	return mime, ""
+}
+
+// isTextMIME reports whether a sniffed MIME type describes textual content.
+func isTextMIME(mime string) bool {
+	if strings.HasPrefix(mime, "text/") {
+		return true
+	}
+	for _, prefix := range []string{"application/json", "application/xml", "application/javascript", "image/svg+xml"} {
+		if strings.HasPrefix(mime, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatSize formats a byte count for display.
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
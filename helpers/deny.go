@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDeniedPaths are sensitive paths that are never included unless
+// explicitly overridden. Patterns use MatchGlob syntax; a leading "!"
+// re-allows paths matched by an earlier pattern.
+var DefaultDeniedPaths = []string{
+	// SSH and other private keys
+	".ssh/", "id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*", "*.pem", "*.key", "*.ppk",
+	// Keystores and certificates bundles with private keys
+	"*.p12", "*.pfx", "*.jks", "*.keystore", "*.kdbx", "*.gpg",
+	// Environment files, keeping the usual templates
+	".env", ".env.*", "!.env.example", "!.env.sample", "!.env.template",
+	// Credential stores
+	".netrc", "_netrc", ".npmrc", ".pypirc", ".pgpass", ".git-credentials", ".htpasswd",
+	"credentials.json", "credentials", "service-account*.json", "*-credentials.json",
+	// Cloud and cluster configuration
+	".aws/", ".azure/", "**/.config/gcloud/", "application_default_credentials.json",
+	"**/.docker/config.json", ".kube/", "kubeconfig", "*.kubeconfig",
+	// Terraform state holds resource secrets in plain text
+	"*.tfstate", "*.tfstate.backup",
+}
+
+// DenyList refuses sensitive paths for every selection mode.
+type DenyList struct {
+	rules []denyRule
+}
+
+// denyRule is a compiled deny-list pattern; allow rules re-allow paths
+// denied by an earlier rule.
+type denyRule struct {
+	glob  Glob
+	allow bool
+}
+
+// NewDenyList creates a deny-list from the default patterns followed by extra ones.
+func NewDenyList(extra []string) *DenyList {
+	d := &DenyList{}
+	for _, pattern := range append(append([]string(nil), DefaultDeniedPaths...), extra...) {
+		allow, isAllow := strings.CutPrefix(pattern, "!")
+		if isAllow {
+			pattern = allow
+		}
+		d.rules = append(d.rules, denyRule{glob: CompileGlob(pattern), allow: isAllow})
+	}
+	return d
+}
+
+// IsDenied reports whether the file at path, relative to rootDir, is sensitive.
+// The last matching pattern decides, as in .gitignore. A symlink is also
+// checked at its target, so a link to a denied file is denied too.
+func (d *DenyList) IsDenied(rootDir, path string) bool {
+	if d.matches(deniedPath(rootDir, path)) {
+		return true
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil || resolved == filepath.Clean(path) {
+		return false
+	}
+	if resolvedRoot, err := filepath.EvalSymlinks(rootDir); err == nil {
+		rootDir = resolvedRoot
+	}
+	return d.matches(deniedPath(rootDir, resolved))
+}
+
+// matches reports whether the patterns deny relPath.
+func (d *DenyList) matches(relPath string) bool {
+	denied := false
+	for _, rule := range d.rules {
+		if rule.allow {
+			if denied && rule.glob.Match(relPath) {
+				denied = false
+			}
+			continue
+		}
+		if rule.glob.Match(relPath) {
+			denied = true
+		}
+	}
+	return denied
+}
+
+// deniedPath returns the path patterns are matched against: path relative
+// to rootDir, or, outside rootDir, the absolute path without its leading
+// separator, so patterns that are not anchored still match.
+func deniedPath(rootDir, path string) string {
+	relPath, err := filepath.Rel(rootDir, path)
+	if err != nil {
+		return path
+	}
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		if abs, err := filepath.Abs(path); err == nil {
+			return strings.TrimLeft(filepath.ToSlash(abs), "/")
+		}
+	}
+	return relPath
+}
+
+// Filter splits files into allowed and denied paths.
+func (d *DenyList) Filter(rootDir string, files []string) ([]string, []string) {
+	var allowed, denied []string
+	for _, file := range files {
+		if d.IsDenied(rootDir, file) {
+			denied = append(denied, file)
+			continue
+		}
+		allowed = append(allowed, file)
+	}
+	return allowed, denied
+}
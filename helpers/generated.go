@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generatedSniffLen is the number of leading bytes read to classify a file.
+const generatedSniffLen = 64 * 1024
+
+var (
+	// generatedFilePatterns matches well-known generated files by name. The
+	// lockfiles listed are those the summarizer cannot read; the others are
+	// selected as usual and summarized when large.
+	generatedFilePatterns = CompileGlobs([]string{
+		"*.pb.go", "*.pb.gw.go", "*_grpc.pb.go", "*.pb.cc", "*.pb.h", "*_pb2.py", "*_pb2_grpc.py", "*_pb2.pyi",
+		"*_pb.js", "*_pb.d.ts", "*_grpc_pb.js", "*.min.js", "*.min.css", "*.bundle.js", "*.chunk.js",
+		"mock_*.go", "*_mock.go", "*_mocks.go", "mocks/", "__mocks__/",
+		"zz_generated*.go", "*_generated.go", "*.generated.*", "*.g.dart", "*.designer.cs",
+		"packages.lock.json", "mix.lock", "Package.resolved", ".terraform.lock.hcl", "flake.lock",
+	})
+
+	// generatedHeaderPatterns match the markers code generators leave near
+	// the top of a file: Go's standard header line, and "@generated" or a
+	// comment of any language opening with a generated notice that says not
+	// to edit the file.
+	generatedHeaderPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`),
+		regexp.MustCompile(`^\s*(?://|#|--|;|/?\*|<!--)\s*.*@generated\b`),
+		regexp.MustCompile(`(?i)^\s*(?://|#|--|;|/?\*|<!--)\s*(?:this (?:file|code) (?:is|was|has been) )?(?:automatically |auto-?)?generated\b.*\bdo not edit\b`),
+	}
+)
+
+const (
+	// generatedHeaderLines is the number of leading lines searched for a generated-file marker.
+	generatedHeaderLines = 20
+	// minifiedLineLen is the length beyond which a line looks minified.
+	minifiedLineLen = 1000
+)
+
+// GeneratedClassifier decides whether files in a project were produced by a tool.
+type GeneratedClassifier struct {
+	rootDir    string
+	attributes []gitAttribute
+}
+
+// gitAttribute is a linguist-generated setting from .gitattributes.
+type gitAttribute struct {
+	pattern   Glob
+	generated bool
+}
+
+// NewGeneratedClassifier creates a classifier for rootDir, honoring
+// linguist-generated attributes in the project's .gitattributes.
+func NewGeneratedClassifier(rootDir string) *GeneratedClassifier {
+	return &GeneratedClassifier{
+		rootDir:    rootDir,
+		attributes: readGeneratedAttributes(filepath.Join(rootDir, ".gitattributes")),
+	}
+}
+
+// Classify reports whether the file at path is generated and, if so, why.
+func (c *GeneratedClassifier) Classify(path string) (bool, string) {
+	relPath, err := filepath.Rel(c.rootDir, path)
+	if err != nil {
+		relPath = path
+	}
+
+	// The last matching attribute wins, as in git.
+	for i := len(c.attributes) - 1; i >= 0; i-- {
+		if c.attributes[i].pattern.Match(relPath) {
+			if !c.attributes[i].generated {
+				return false, ""
+			}
+			return true, "linguist-generated in .gitattributes"
+		}
+	}
+
+	for _, pattern := range generatedFilePatterns {
+		if pattern.Match(relPath) {
+			return true, "matches " + pattern.String()
+		}
+	}
+
+	// Notebooks embed outputs such as base64 images on single long lines,
+	// which would otherwise look minified.
+	if IsNotebook(path) {
+		return false, ""
+	}
+
+	head, err := readHead(path, generatedSniffLen)
+	if err != nil {
+		return false, ""
+	}
+	return classifyGeneratedContent(head)
+}
+
+// classifyGeneratedContent looks for generator headers and minified content.
+func classifyGeneratedContent(head []byte) (bool, string) {
+	lines := strings.Split(string(head), "\n")
+
+	for i, line := range lines {
+		if i >= generatedHeaderLines {
+			break
+		}
+		line = strings.TrimSuffix(line, "\r")
+		for _, pattern := range generatedHeaderPatterns {
+			if pattern.MatchString(line) {
+				return true, "generated header"
+			}
+		}
+	}
+
+	// Minified bundles hold most of their content on extremely long lines,
+	// while a hand-written file may have the odd long line, such as a data
+	// URI or an embedded key.
+	if len(head) >= 1024 {
+		long := 0
+		for _, line := range lines {
+			if len(line) > minifiedLineLen {
+				long += len(line)
+			}
+		}
+		if long*2 > len(head) {
+			return true, "minified"
+		}
+	}
+
+	return false, ""
+}
+
+// readGeneratedAttributes parses the linguist-generated entries of a .gitattributes file.
+func readGeneratedAttributes(path string) []gitAttribute {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var attributes []gitAttribute
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "linguist-generated", "linguist-generated=true":
+				attributes = append(attributes, gitAttribute{pattern: CompileGlob(fields[0]), generated: true})
+			case "-linguist-generated", "linguist-generated=false":
+				attributes = append(attributes, gitAttribute{pattern: CompileGlob(fields[0]), generated: false})
+			}
+		}
+	}
+	return attributes
+}
+
+// readHead reads at most n bytes from the start of a file.
+func readHead(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, n))
+}
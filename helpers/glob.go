@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MatchGlob reports whether a slash-separated path relative to the project root
+// matches a gitignore-style pattern. Patterns without a slash match the file
+// name at any depth, a leading slash anchors the pattern to the root, "*" and
+// "?" do not cross directory boundaries, and "**" matches any number of
+// directories. Callers matching a pattern repeatedly should compile it once
+// with CompileGlob.
+func MatchGlob(pattern, relPath string) bool {
+	return CompileGlob(pattern).Match(relPath)
+}
+
+// Glob is a compiled gitignore-style pattern.
+type Glob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// CompileGlob compiles a pattern in MatchGlob syntax.
+func CompileGlob(pattern string) Glob {
+	expr := strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(expr, "/") {
+		expr = "**/" + expr
+	}
+	expr = strings.TrimPrefix(expr, "/")
+
+	// An invalid expression leaves re nil, which matches nothing.
+	re, _ := regexp.Compile(globToRegexp(expr))
+	return Glob{pattern: pattern, re: re}
+}
+
+// CompileGlobs compiles each of the patterns.
+func CompileGlobs(patterns []string) []Glob {
+	globs := make([]Glob, len(patterns))
+	for i, pattern := range patterns {
+		globs[i] = CompileGlob(pattern)
+	}
+	return globs
+}
+
+// Match reports whether a slash-separated path relative to the project root
+// matches the pattern.
+func (g Glob) Match(relPath string) bool {
+	return g.re != nil && g.re.MatchString(filepath.ToSlash(relPath))
+}
+
+// String returns the pattern as written.
+func (g Glob) String() string {
+	return g.pattern
+}
+
+// globToRegexp translates a glob into an anchored regular expression. A match
+// of a directory also matches everything below it.
+func globToRegexp(pattern string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("(?:/.*)?$")
+	return re.String()
+}
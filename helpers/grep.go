@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CompileGrepPattern compiles a --grep pattern. A bare identifier such as
+// "ParseConfig" is matched as a whole word so that symbol names do not match
+// longer identifiers that merely contain them; anything else is used as a
+// regular expression verbatim.
+func CompileGrepPattern(pattern string) (*regexp.Regexp, error) {
+	if identifierPattern.MatchString(pattern) {
+		pattern = `\b` + pattern + `\b`
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grep pattern %q: %v", pattern, err)
+	}
+	return re, nil
+}
+
+// GrepFiles walks the directory tree, skipping ignored directories, and returns
+// the files whose contents match the given pattern.
+func GrepFiles(rootDir string, re *regexp.Regexp) ([]string, error) {
+	var matchedFiles []string
+
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != rootDir && IsIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		if _, reason := SniffBinary(content); reason != "" {
+			return nil
+		}
+		if re.Match(content) {
+			matchedFiles = append(matchedFiles, path)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk the directory: %v", err)
+	}
+
+	return matchedFiles, nil
+}
+
+// MatchWindows returns only the lines of content within contextLines of a match,
+// prefixed with their line numbers. Overlapping windows are merged and separate
+// windows are divided by a "..." line.
+func MatchWindows(content string, re *regexp.Regexp, contextLines int) string {
+	lines := strings.Split(content, "\n")
+	keep := make([]bool, len(lines))
+
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		start := max(0, i-contextLines)
+		end := min(len(lines)-1, i+contextLines)
+		for j := start; j <= end; j++ {
+			keep[j] = true
+		}
+	}
+
+	width := len(strconv.Itoa(len(lines)))
+	var windows strings.Builder
+	inWindow := false
+	for i, line := range lines {
+		if !keep[i] {
+			inWindow = false
+			continue
+		}
+		if !inWindow && windows.Len() > 0 {
+			windows.WriteString("...\n")
+		}
+		inWindow = true
+		windows.WriteString(fmt.Sprintf("%*d: %s\n", width, i+1, line))
+	}
+
+	return windows.String()
+}
@@ -1,7 +1,6 @@
 package helpers
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,22 +8,48 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 
 	"codecopy/constants"
-	"github.com/manifoldco/promptui"
+	"codecopy/languages"
 	"github.com/tiktoken-go/tokenizer"
+	"golang.org/x/term"
 )
 
-// ReadFileContent reads the content of a file.
-func ReadFileContent(filePath string) (string, error) {
+// ReadFileContent reads the content of a file. Files larger than limit bytes
+// are rejected with a *FileTooLargeError without being read, unless limit is
+// 0, and files that do not contain text with a *BinaryFileError.
+func ReadFileContent(filePath string, limit int64) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	if limit > 0 && info.Size() > limit {
+		return "", &FileTooLargeError{Path: filePath, Size: info.Size(), Limit: limit}
+	}
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file %s: %v", filePath, err)
+		return "", fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	if mime, reason := SniffBinary(content); reason != "" {
+		return "", &BinaryFileError{Path: filePath, Size: int64(len(content)), MIME: mime, Reason: reason}
 	}
 	return string(content), nil
 }
 
+// FileTooLargeError is returned by ReadFileContent for files over the size
+// limit, which are not read at all.
+type FileTooLargeError struct {
+	Path  string
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("%s is %s, over the %s limit", e.Path, FormatSize(e.Size), FormatSize(e.Limit))
+}
+
 // CountTokens counts the number of tokens in the given content.
 func CountTokens(content string) (int, error) {
 	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
@@ -40,6 +65,11 @@ func CountTokens(content string) (int, error) {
 	return len(ids), nil
 }
 
+// IsTerminal reports whether file is an interactive terminal.
+func IsTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
+
 // WriteToFile writes the given content to a file.
 func WriteToFile(content, filename string) error {
 	err := os.WriteFile(filename, []byte(content), 0644)
@@ -49,17 +79,6 @@ func WriteToFile(content, filename string) error {
 	return nil
 }
 
-// GenerateTree generates a tree representation of the directory structure.
-func GenerateTree(rootDir string) (string, error) {
-	cmd := exec.Command("tree", "-F", "-I", strings.Join(constants.IgnoredDirs, "|"))
-	cmd.Dir = rootDir
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate tree: %v", err)
-	}
-	return string(output), nil
-}
-
 // CopyToClipboard copies the given content to the system clipboard.
 func CopyToClipboard(content string) error {
 	var cmd *exec.Cmd
@@ -120,37 +139,69 @@ func ContainsFlag(args []string, flag string) bool {
 	return false
 }
 
-// GetSelectedLanguage retrieves the selected language flag from the command-line arguments.
-func GetSelectedLanguage(args []string, languageFlags []string) string {
-	for _, arg := range args {
-		if Contains(languageFlags, arg) {
-			return arg
+// GetFlagValue retrieves the value of a flag given either as "--flag value" or
+// "--flag=value". The boolean result reports whether the flag was present.
+func GetFlagValue(args []string, flag string) (string, bool) {
+	for i, arg := range args {
+		if arg == flag {
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", true
+		}
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"="), true
 		}
 	}
-	return ""
+	return "", false
 }
 
-// DisplayHelp displays the help message for the codecopy command.
-
-// ExtractFilesFromTree extracts the file paths from the generated tree output.
-func ExtractFilesFromTree(treeOutput string) []string {
-	var files []string
+// GetIntFlag retrieves the non-negative integer value of a flag, returning 0
+// when the flag is absent.
+func GetIntFlag(args []string, flag string) (int, error) {
+	value, ok := GetFlagValue(args, flag)
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s value %q: must be a non-negative number", flag, value)
+	}
+	return n, nil
+}
 
-	lines := strings.Split(treeOutput, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "├──") || strings.Contains(line, "└──") {
-			file := strings.TrimSpace(line[4:])
-			if !strings.HasSuffix(file, "/") {
-				files = append(files, file)
+// GetFlagValues retrieves every value given for a repeatable flag. Each value
+// may also hold a comma-separated list.
+func GetFlagValues(args []string, flag string) []string {
+	var values []string
+	for i, arg := range args {
+		var value string
+		switch {
+		case arg == flag && i+1 < len(args):
+			value = args[i+1]
+		case strings.HasPrefix(arg, flag+"="):
+			value = strings.TrimPrefix(arg, flag+"=")
+		default:
+			continue
+		}
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
 			}
 		}
 	}
+	return values
+}
 
-	return files
+// IsIgnoredDir checks if a directory name is one of the ignored directories.
+func IsIgnoredDir(name string) bool {
+	return Contains(constants.IgnoredDirs, name)
 }
 
-// SelectFiles prompts the user to select files or directories to include.
-func SelectFiles(rootDir string) ([]string, error) {
+// DisplayHelp displays the help message for the codecopy command.
+
+// ListFiles returns every file under rootDir outside ignored directories.
+func ListFiles(rootDir string) ([]string, error) {
 	var files []string
 
 	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
@@ -158,10 +209,14 @@ func SelectFiles(rootDir string) ([]string, error) {
 			return err
 		}
 
-		if !info.IsDir() && !Contains(constants.IgnoredDirs, filepath.Base(path)) {
-			files = append(files, path)
+		if info.IsDir() {
+			if path != rootDir && IsIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
+		files = append(files, path)
 		return nil
 	})
 
@@ -169,26 +224,12 @@ func SelectFiles(rootDir string) ([]string, error) {
 		return nil, fmt.Errorf("failed to walk the directory: %v", err)
 	}
 
-	selectedFiles, err := multiSelectPrompt("Select files/directories to include", files)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform file selection: %v", err)
-	}
-
-	return selectedFiles, nil
-}
-
-// SelectFilesToRemove prompts the user to select files or directories to remove.
-func SelectFilesToRemove(selectedFiles []string) ([]string, error) {
-	removedFiles, err := multiSelectPrompt("Select files/directories to remove", selectedFiles)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform file selection: %v", err)
-	}
-
-	return removedFiles, nil
+	return files, nil
 }
 
 // BuildTreeWithTokenCounts constructs the project directory tree with token counts for each file.
-func BuildTreeWithTokenCounts(rootDir string, selectedFiles []string, fileTokenCounts map[string]int) []string {
+// Any note recorded for a file, such as a truncation marker, is shown next to its token count.
+func BuildTreeWithTokenCounts(rootDir string, selectedFiles []string, fileTokenCounts map[string]int, fileNotes map[string]string) []string {
 	var treeWithTokenCounts []string
 
 	// Create a map to store directory paths and their corresponding files
@@ -229,6 +270,10 @@ func BuildTreeWithTokenCounts(rootDir string, selectedFiles []string, fileTokenC
 				continue // Skip the executable file
 			}
 			line := fmt.Sprintf("%s  └── %s", indent, fileName)
+			if note := fileNotes[filePath]; note != "" {
+				treeWithTokenCounts = append(treeWithTokenCounts, fmt.Sprintf("%-25s | %d %s", line, tokenCount, note))
+				continue
+			}
 			treeWithTokenCounts = append(treeWithTokenCounts, fmt.Sprintf("%-25s | %d", line, tokenCount))
 		}
 	}
@@ -244,177 +289,90 @@ func BuildTreeWithTokenCounts(rootDir string, selectedFiles []string, fileTokenC
 	return treeWithTokenCounts
 }
 
-func multiSelectPrompt(label string, items []string) ([]string, error) {
-	searcher := func(input string, index int) bool {
-		return strings.Contains(items[index], input)
-	}
-
-	templates := &promptui.SelectTemplates{
-		Label:    "{{ . }}",
-		Active:   "\U0001F449 {{ . | cyan }}",
-		Inactive: "  {{ . | cyan }}",
-		Selected: "\U00002705 {{ . | green }}",
-	}
-
-	prompt := promptui.Select{
-		Label:     label,
-		Items:     items,
-		Templates: templates,
-		Size:      10,
-		HideHelp:  true,
-		IsVimMode: true,
-		Searcher:  searcher,
-	}
-
-	var selectedItems []string
+// GetRelevantFiles retrieves the relevant files based on the selected language and the detected projects.
+// Without a selected language, a file is relevant if it belongs to the file set of any project that contains it,
+// so mixed repositories get the union of each sub-project's files, or if it is a build or deployment file.
+func GetRelevantFiles(rootDir string, registry *languages.Registry, projects []Project, selectedLanguage *languages.Language) ([]string, error) {
+	var relevantFiles []string
 
-	for {
-		index, _, err := prompt.Run()
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			if errors.Is(err, promptui.ErrInterrupt) {
-				break
+			return err
+		}
+		if info.IsDir() {
+			if path != rootDir && IsIgnoredDir(info.Name()) {
+				return filepath.SkipDir
 			}
-			return nil, err
+			return nil
 		}
 
-		selectedItem := items[index]
-		selectedItems = append(selectedItems, selectedItem)
-
-		items = append(items[:index], items[index+1:]...)
-
-		if len(items) == 0 {
-			break
+		if selectedLanguage != nil {
+			if IsRelevantFile(registry, path, selectedLanguage) {
+				relevantFiles = append(relevantFiles, path)
+			}
+			return nil
 		}
-
-		prompt.Items = items
-		prompt.Label = "Select another file/directory or press Enter to continue"
-	}
-
-	return selectedItems, nil
-}
-
-// DetectProjectType detects the project type based on the file extensions in the root directory.
-func DetectProjectType(rootDir string) (string, error) {
-	fileTypes := make(map[string]int)
-
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+		if len(projects) > 0 && IsBuildFile(registry, path) {
+			relevantFiles = append(relevantFiles, path)
+			return nil
 		}
-		if !info.IsDir() {
-			ext := filepath.Ext(path)
-			fileTypes[ext]++
+		if isCovered(registry, projects, path) {
+			relevantFiles = append(relevantFiles, path)
 		}
 		return nil
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to walk the directory: %v", err)
+		return nil, fmt.Errorf("failed to walk the directory: %v", err)
 	}
 
-	var projectType string
-	var maxCount int
+	return relevantFiles, nil
+}
 
-	for ext, count := range fileTypes {
-		switch ext {
-		case ".go":
-			if count > maxCount {
-				maxCount = count
-				projectType = "Go"
-			}
-		case ".py":
-			if count > maxCount {
-				maxCount = count
-				projectType = "Python"
-			}
-		case ".js", ".ts":
-			if count > maxCount {
-				maxCount = count
-				projectType = "JavaScript/TypeScript"
-			}
-		case ".rs":
-			if count > maxCount {
-				maxCount = count
-				projectType = "Rust"
-			}
-		case ".php":
-			if count > maxCount {
-				maxCount = count
-				projectType = "PHP"
-			}
-		}
+// IsRelevantFile checks if a file belongs to the file set of the given language, considering its full
+// file name as well as its extension, or is a build or deployment file relevant to every project.
+func IsRelevantFile(registry *languages.Registry, path string, language *languages.Language) bool {
+	if language == nil {
+		return false
 	}
+	return language.IncludesFile(path) || IsBuildFile(registry, path)
+}
 
-	if projectType == "" {
-		projectType = "Unknown"
+// IsBuildFile checks if a file is build or deployment context relevant to every project: a common file
+// such as a Makefile or Dockerfile, or an extensionless script whose shebang names a known interpreter.
+func IsBuildFile(registry *languages.Registry, path string) bool {
+	if registry.IsCommonFile(path) {
+		return true
 	}
-
-	return projectType, nil
+	if filepath.Ext(path) != "" {
+		return false
+	}
+	interpreter := ShebangInterpreter(path)
+	return interpreter != "" && registry.ByInterpreter(interpreter) != nil
 }
 
-// GetRelevantFiles retrieves the relevant files based on the selected language and project type.
-func GetRelevantFiles(rootDir, projectType, selectedLanguage string) ([]string, error) {
-	var relevantFiles []string
-
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			ext := filepath.Ext(path)
-			if IsRelevantFile(ext, selectedLanguage, projectType) {
-				relevantFiles = append(relevantFiles, path)
-			}
-		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to walk the directory: %v", err)
+// ShebangInterpreter returns the interpreter named on a file's "#!" line, resolving "/usr/bin/env", or "".
+func ShebangInterpreter(path string) string {
+	head, err := readHead(path, 256)
+	if err != nil || !strings.HasPrefix(string(head), "#!") {
+		return ""
 	}
 
-	return relevantFiles, nil
-}
+	line, _, _ := strings.Cut(string(head[2:]), "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
 
-// IsRelevantFile checks if a file is relevant based on the selected language and project type.
-func IsRelevantFile(ext, selectedLanguage, projectType string) bool {
-	switch selectedLanguage {
-	case "-py":
-		return Contains(constants.PythonFiles, ext)
-	case "-rs":
-		return Contains(constants.RustFiles, ext)
-	case "-go":
-		return Contains(constants.GoFiles, ext)
-	case "-js":
-		return Contains(constants.JavaScriptFiles, ext)
-	case "-php":
-		return Contains(constants.PHPFiles, ext)
-	case "-java":
-		return Contains(constants.JavaFiles, ext)
-	case "-rb":
-		return Contains(constants.RubyFiles, ext)
-	case "-cs":
-		return Contains(constants.CSharpFiles, ext)
-	default:
-		switch projectType {
-		case "Go":
-			return Contains(constants.GoFiles, ext)
-		case "Python":
-			return Contains(constants.PythonFiles, ext)
-		case "JavaScript/TypeScript":
-			return Contains(constants.JavaScriptFiles, ext)
-		case "Rust":
-			return Contains(constants.RustFiles, ext)
-		case "PHP":
-			return Contains(constants.PHPFiles, ext)
-		case "Java":
-			return Contains(constants.JavaFiles, ext)
-		case "Ruby":
-			return Contains(constants.RubyFiles, ext)
-		case "C#":
-			return Contains(constants.CSharpFiles, ext)
-		default:
-			return false
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
 		}
 	}
+	return interpreter
 }
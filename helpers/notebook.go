@@ -0,0 +1,155 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NotebookOptions controls how Jupyter notebooks are rendered.
+type NotebookOptions struct {
+	// OutputLines keeps up to this many lines of each cell output; 0 drops outputs.
+	OutputLines int
+}
+
+// notebook is the subset of the Jupyter notebook format (nbformat 4) that is rendered.
+type notebook struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []notebookCell `json:"cells"`
+}
+
+type notebookCell struct {
+	CellType       string           `json:"cell_type"`
+	Source         notebookText     `json:"source"`
+	ExecutionCount *int             `json:"execution_count"`
+	Outputs        []notebookOutput `json:"outputs"`
+}
+
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Text       notebookText            `json:"text"`
+	Data       map[string]notebookText `json:"data"`
+	EName      string                  `json:"ename"`
+	EValue     string                  `json:"evalue"`
+}
+
+// notebookText is multiline notebook text, stored either as one string or as a list of lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		// Non-text data, such as application/json outputs, is not rendered.
+		*t = ""
+		return nil
+	}
+	*t = notebookText(text)
+	return nil
+}
+
+// ansiEscapePattern matches terminal color codes found in tracebacks and stream outputs.
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// IsNotebook reports whether the path is a Jupyter notebook.
+func IsNotebook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// RenderNotebook extracts the cells of a Jupyter notebook in order, as
+// numbered code and markdown sections. Outputs are dropped unless
+// opts.OutputLines is set, in which case text outputs are truncated to that
+// many lines and images are replaced by a placeholder.
+func RenderNotebook(content string, opts NotebookOptions) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal([]byte(content), &nb); err != nil {
+		return "", fmt.Errorf("failed to parse notebook: %v", err)
+	}
+	if nb.NBFormat < 4 {
+		return "", fmt.Errorf("unsupported notebook format %d", nb.NBFormat)
+	}
+
+	language := nb.Metadata.KernelSpec.Language
+	if language == "" {
+		language = nb.Metadata.LanguageInfo.Name
+	}
+
+	var b strings.Builder
+	if language != "" {
+		b.WriteString(fmt.Sprintf("Jupyter notebook (%s), %d cells\n", language, len(nb.Cells)))
+	} else {
+		b.WriteString(fmt.Sprintf("Jupyter notebook, %d cells\n", len(nb.Cells)))
+	}
+
+	for i, cell := range nb.Cells {
+		header := fmt.Sprintf("# %%%% [%d] %s", i+1, cell.CellType)
+		if cell.ExecutionCount != nil {
+			header += fmt.Sprintf(" (In [%d])", *cell.ExecutionCount)
+		}
+		b.WriteString("\n" + header + "\n")
+		b.WriteString(strings.TrimRight(string(cell.Source), "\n"))
+		b.WriteString("\n")
+
+		if opts.OutputLines == 0 || len(cell.Outputs) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("\n# %%%% [%d] output\n", i+1))
+		for _, output := range cell.Outputs {
+			b.WriteString(renderNotebookOutput(output, opts.OutputLines))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// renderNotebookOutput renders one cell output truncated to maxLines lines.
+func renderNotebookOutput(output notebookOutput, maxLines int) string {
+	var text string
+	switch output.OutputType {
+	case "stream":
+		text = string(output.Text)
+	case "error":
+		text = fmt.Sprintf("%s: %s", output.EName, output.EValue)
+	case "execute_result", "display_data":
+		if plain, ok := output.Data["text/plain"]; ok {
+			text = string(plain)
+		}
+		var placeholders []string
+		for mime := range output.Data {
+			if strings.HasPrefix(mime, "image/") {
+				placeholders = append(placeholders, fmt.Sprintf("[%s output omitted]", mime))
+			}
+		}
+		sort.Strings(placeholders)
+		if len(placeholders) > 0 {
+			text = strings.Join(placeholders, "\n")
+		}
+	}
+
+	text = strings.TrimRight(ansiEscapePattern.ReplaceAllString(text, ""), "\n")
+	if text == "" {
+		return ""
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) > maxLines {
+		omitted := len(lines) - maxLines
+		lines = append(lines[:maxLines], fmt.Sprintf("... (%d more lines)", omitted))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
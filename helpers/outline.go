@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// outlineLinePattern matches lines that start a declaration in most languages.
+var outlineLinePattern = regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(pub(\([^)]*\))?\s+)?(public\s+|private\s+|protected\s+|internal\s+)?(static\s+|abstract\s+|final\s+|async\s+)*(func|function|class|interface|type|enum|struct|trait|impl|fn|def|module|message|service|rpc|record|object|namespace)\b`)
+
+// maxOutlineLineLen caps the length of a single outline line.
+const maxOutlineLineLen = 200
+
+// Outline reduces a file to its declarations. Go files keep their type
+// declarations, constants and function signatures; other languages keep the
+// lines that appear to start a declaration.
+func Outline(path, content string) string {
+	if filepath.Ext(path) == ".go" {
+		if outline, ok := goOutline(path, content); ok {
+			return outline
+		}
+	}
+
+	var outline strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		if !outlineLinePattern.MatchString(line) {
+			continue
+		}
+		line = strings.TrimRight(line, " \t{")
+		if len(line) > maxOutlineLineLen {
+			line = line[:maxOutlineLineLen] + "..."
+		}
+		outline.WriteString(line)
+		outline.WriteString("\n")
+	}
+	return outline.String()
+}
+
+// goOutline prints a Go file's package clause, types, constants and function
+// signatures without bodies or variable initializers.
+func goOutline(path, content string) (string, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, 0)
+	if err != nil {
+		return "", false
+	}
+
+	var outline bytes.Buffer
+	outline.WriteString("package " + file.Name.Name + "\n")
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			decl.Body = nil
+		case *ast.GenDecl:
+			switch decl.Tok {
+			case token.IMPORT:
+				continue
+			case token.VAR:
+				for _, spec := range decl.Specs {
+					spec.(*ast.ValueSpec).Values = nil
+				}
+			}
+		}
+		outline.WriteString("\n")
+		if err := printer.Fprint(&outline, fset, decl); err != nil {
+			return "", false
+		}
+		outline.WriteString("\n")
+	}
+
+	return outline.String(), true
+}
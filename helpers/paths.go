@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadPaths reads newline-separated paths, skipping blank lines.
+func ReadPaths(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if path := strings.TrimSpace(scanner.Text()); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read paths: %v", err)
+	}
+	return paths, nil
+}
+
+// ExpandPaths resolves explicitly given paths against rootDir. Directories are
+// expanded to the files below them, skipping ignored directories.
+func ExpandPaths(rootDir string, paths []string) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(rootDir, path)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access %s: %v", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if p != path && IsIgnoredDir(info.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			files = append(files, p)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk the directory: %v", err)
+		}
+	}
+
+	return files, nil
+}
+
+// IsWithin reports whether path is root or lies below it.
+func IsWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// RelativePath returns file relative to rootDir with forward slashes, or file
+// itself when it lies outside rootDir.
+func RelativePath(rootDir, file string) string {
+	if !IsWithin(rootDir, file) {
+		return file
+	}
+	rel, _ := filepath.Rel(rootDir, file)
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codecopy/languages"
+)
+
+// Project is a language sub-project rooted at the directory holding its manifest.
+type Project struct {
+	Language string
+	Root     string
+	Manifest string
+	// SourceOnly limits the project to its language's source files, for a
+	// project inferred from source files beside projects with manifests.
+	SourceOnly bool
+}
+
+// DetectProjects finds every sub-project under rootDir from its build manifest
+// (go.mod, package.json, Cargo.toml, ...), so a repository mixing languages
+// yields one project per language root. Among the source files no detected
+// project covers, such as scripts outside every project or the whole tree
+// when there is no manifest, the most common language adds a project at
+// rootDir.
+func DetectProjects(rootDir string, registry *languages.Registry) ([]Project, error) {
+	var projects []Project
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != rootDir && IsIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		language := registry.ByManifest(info.Name())
+		if language == nil {
+			return nil
+		}
+		root := filepath.Dir(path)
+		if key := language.Name + "\x00" + root; !seen[key] {
+			seen[key] = true
+			projects = append(projects, Project{Language: language.Name, Root: root, Manifest: path})
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk the directory: %v", err)
+	}
+
+	language, err := detectLanguageByExtension(rootDir, registry, projects)
+	if err != nil {
+		return nil, err
+	}
+	if language != "" && !seen[language+"\x00"+rootDir] {
+		projects = append(projects, Project{Language: language, Root: rootDir, SourceOnly: len(projects) > 0})
+	}
+
+	return projects, nil
+}
+
+// detectLanguageByExtension picks the language with the most source files
+// under rootDir that none of the projects covers.
+func detectLanguageByExtension(rootDir string, registry *languages.Registry, projects []Project) (string, error) {
+	counts := make(map[string]int)
+
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != rootDir && IsIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if language := registry.BySourceExtension(filepath.Ext(path)); language != nil && !isCovered(registry, projects, path) {
+			counts[language.Name]++
+		}
+		return nil
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to walk the directory: %v", err)
+	}
+
+	var language string
+	var maxCount int
+	for candidate, count := range counts {
+		if count > maxCount || (count == maxCount && candidate < language) {
+			language, maxCount = candidate, count
+		}
+	}
+	return language, nil
+}
+
+// isCovered reports whether a project containing path includes it in its file set.
+func isCovered(registry *languages.Registry, projects []Project, path string) bool {
+	for _, project := range projects {
+		if IsWithin(project.Root, path) && project.includes(registry, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// includes reports whether path belongs to the project's file set, assuming
+// it lies below the project root.
+func (p Project) includes(registry *languages.Registry, path string) bool {
+	if p.SourceOnly {
+		language := registry.BySourceExtension(filepath.Ext(path))
+		return language != nil && language.Name == p.Language
+	}
+	return IsRelevantFile(registry, path, registry.ByName(p.Language))
+}
+
+// DescribeProjects summarizes the detected projects for display.
+func DescribeProjects(rootDir string, projects []Project) string {
+	if len(projects) == 0 {
+		return "Unknown"
+	}
+
+	descriptions := make([]string, 0, len(projects))
+	for _, project := range projects {
+		relRoot, err := filepath.Rel(rootDir, project.Root)
+		if err != nil || relRoot == "." {
+			descriptions = append(descriptions, project.Language)
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", project.Language, filepath.ToSlash(relRoot)))
+	}
+	sort.Strings(descriptions)
+	return strings.Join(descriptions, ", ")
+}
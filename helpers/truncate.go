@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncateLimits caps the size of a single file's content. A zero value
+// disables the corresponding limit.
+type TruncateLimits struct {
+	MaxBytes  int
+	MaxLines  int
+	MaxTokens int
+}
+
+// IsSet reports whether any limit is enabled.
+func (l TruncateLimits) IsSet() bool {
+	return l.MaxBytes > 0 || l.MaxLines > 0 || l.MaxTokens > 0
+}
+
+// TruncateContent shortens content that exceeds the limits to its first and
+// last lines, joined by an "... N lines omitted ..." marker. When not even one
+// whole line fits, the content is cut within its lines instead, joined by an
+// "... N bytes omitted ..." marker. It returns the resulting content and the
+// number of lines omitted in whole or in part.
+func TruncateContent(content string, limits TruncateLimits) (string, int, error) {
+	body, hasFinalNewline := strings.CutSuffix(content, "\n")
+	lines := strings.Split(body, "\n")
+
+	fits, err := limits.fits(content, len(lines))
+	if err != nil || fits {
+		return content, 0, err
+	}
+
+	// Find the largest number of kept lines that satisfies every limit.
+	var fitErr error
+	keep := sort.Search(len(lines), func(k int) bool {
+		ok, err := limits.fits(keepHeadAndTail(lines, k), k)
+		if err != nil {
+			fitErr = err
+		}
+		return !ok
+	}) - 1
+	if fitErr != nil {
+		return content, 0, fitErr
+	}
+	if keep <= 0 {
+		return limits.truncateBytes(content)
+	}
+
+	truncated := keepHeadAndTail(lines, keep)
+	if hasFinalNewline {
+		truncated += "\n"
+	}
+	return truncated, len(lines) - keep, nil
+}
+
+// fits reports whether text, made of lineCount original lines, is within the limits.
+func (l TruncateLimits) fits(text string, lineCount int) (bool, error) {
+	if l.MaxLines > 0 && lineCount > l.MaxLines {
+		return false, nil
+	}
+	if l.MaxBytes > 0 && len(text) > l.MaxBytes {
+		return false, nil
+	}
+	if l.MaxTokens > 0 {
+		tokenCount, err := CountTokens(text)
+		if err != nil {
+			return false, err
+		}
+		if tokenCount > l.MaxTokens {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// truncateBytes cuts content to the most bytes of its start and end that
+// satisfy every limit, for content whose lines are too long to keep whole.
+func (l TruncateLimits) truncateBytes(content string) (string, int, error) {
+	body, hasFinalNewline := strings.CutSuffix(content, "\n")
+
+	var fitErr error
+	keep := sort.Search(len(body), func(n int) bool {
+		text := keepHeadAndTailBytes(body, n)
+		ok, err := l.fits(text, strings.Count(text, "\n")+1)
+		if err != nil {
+			fitErr = err
+		}
+		return !ok
+	}) - 1
+	if fitErr != nil {
+		return content, 0, fitErr
+	}
+	keep = max(keep, 0)
+
+	head, tail := headAndTailBytes(body, keep)
+	truncated := keepHeadAndTailBytes(body, keep)
+	if hasFinalNewline {
+		truncated += "\n"
+	}
+	return truncated, strings.Count(body[head:tail], "\n") + 1, nil
+}
+
+// FormatLines formats a line count for display, such as "1 line" or "3 lines".
+func FormatLines(n int) string {
+	if n == 1 {
+		return "1 line"
+	}
+	return fmt.Sprintf("%d lines", n)
+}
+
+// keepHeadAndTail keeps keep lines split between the start and end of lines.
+func keepHeadAndTail(lines []string, keep int) string {
+	if keep >= len(lines) {
+		return strings.Join(lines, "\n")
+	}
+
+	head := (keep + 1) / 2
+	tail := keep - head
+
+	parts := make([]string, 0, keep+1)
+	parts = append(parts, lines[:head]...)
+	parts = append(parts, fmt.Sprintf("... %s omitted ...", FormatLines(len(lines)-keep)))
+	parts = append(parts, lines[len(lines)-tail:]...)
+	return strings.Join(parts, "\n")
+}
+
+// keepHeadAndTailBytes keeps about keep bytes split between the start and
+// end of body, never splitting a character.
+func keepHeadAndTailBytes(body string, keep int) string {
+	head, tail := headAndTailBytes(body, keep)
+	if head >= tail {
+		return body
+	}
+	return fmt.Sprintf("%s ... %d bytes omitted ... %s", body[:head], tail-head, body[tail:])
+}
+
+// headAndTailBytes returns the end of the kept start of body and the start
+// of its kept end, both on character boundaries.
+func headAndTailBytes(body string, keep int) (int, int) {
+	if keep >= len(body) {
+		return len(body), len(body)
+	}
+	head := (keep + 1) / 2
+	tail := len(body) - (keep - head)
+	for head > 0 && !utf8.RuneStart(body[head]) {
+		head--
+	}
+	for tail < len(body) && !utf8.RuneStart(body[tail]) {
+		tail++
+	}
+	return head, tail
+}
@@ -0,0 +1,312 @@
+package languages
+
+var (
+	cStyleComments = Comments{
+		Line:  []string{"//"},
+		Block: []BlockComment{{Start: "/*", End: "*/"}},
+	}
+
+	nestedCStyleComments = Comments{
+		Line:   []string{"//"},
+		Block:  []BlockComment{{Start: "/*", End: "*/"}},
+		Nested: true,
+	}
+
+	hashComments = Comments{
+		Line: []string{"#"},
+	}
+
+	// shellComments follow POSIX shell quoting: single-quoted strings have
+	// no escapes and, like double-quoted ones, may span lines.
+	shellComments = Comments{
+		Line: []string{"#"},
+		Strings: []StringLiteral{
+			{Delimiter: `"`, Multiline: true},
+			{Delimiter: `'`, Multiline: true, Raw: true},
+			{Delimiter: "`", Multiline: true},
+		},
+		Heredocs: true,
+	}
+)
+
+// builtinCommonFilenames are build and deployment files included for every project.
+var builtinCommonFilenames = []string{
+	"Makefile", "makefile", "GNUmakefile", "*.mk", "Justfile", "justfile",
+	"Dockerfile", "Dockerfile.*", "*.Dockerfile", "Containerfile", ".dockerignore",
+	"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml",
+	"Procfile", "Vagrantfile", "Jenkinsfile", "Tiltfile", "Taskfile.yml", "Taskfile.yaml",
+	".env.example", ".env.sample", ".env.template", ".editorconfig", ".tool-versions", "go.work",
+}
+
+// builtinLanguages is the default language registry.
+var builtinLanguages = []Language{
+	{
+		Name:  "Go",
+		Flags: []string{"-go"},
+		Extensions: []string{
+			".go", ".mod", ".sum", ".toml", ".yaml", ".yml", ".json", ".md", ".txt",
+		},
+		Manifests:        []string{"go.mod"},
+		SourceExtensions: []string{".go"},
+		Comments:         cStyleComments,
+		FenceTag:         "go",
+	},
+	{
+		Name:  "Python",
+		Flags: []string{"-py"},
+		Extensions: []string{
+			".py", ".pyc", ".pyd", ".pyo", ".pyw", ".pyz", ".pyi", ".ipynb", ".ini", ".toml",
+			".yaml", ".yml", ".json", ".lock", ".md", ".txt",
+		},
+		Interpreters:      []string{"python", "python2", "python3", "pypy", "pypy3"},
+		Manifests:         []string{"pyproject.toml", "setup.py", "requirements.txt"},
+		SourceExtensions:  []string{".py"},
+		Comments:          hashComments,
+		FenceTag:          "python",
+		SignificantIndent: true,
+	},
+	{
+		Name:  "JavaScript/TypeScript",
+		Flags: []string{"-js"},
+		Extensions: []string{
+			".js", ".mjs", ".cjs", ".ts", ".tsx", ".jsx", ".es6", ".es", ".json",
+			".jsonc", ".json5", ".css", ".scss", ".sass", ".less", ".styl", ".html",
+			".htm", ".xhtml", ".vue", ".svelte", ".angular", ".yaml", ".yml", ".toml",
+			".ini", ".lock", ".md", ".txt",
+		},
+		Interpreters:     []string{"node", "nodejs", "deno", "bun", "ts-node", "tsx"},
+		Manifests:        []string{"package.json"},
+		SourceExtensions: []string{".js", ".ts"},
+		Comments:         cStyleComments,
+		FenceTag:         "javascript",
+	},
+	{
+		Name:  "Rust",
+		Flags: []string{"-rs"},
+		Extensions: []string{
+			".rs", ".toml", ".lock", ".yaml", ".yml", ".json", ".md", ".txt",
+		},
+		Manifests:        []string{"Cargo.toml"},
+		SourceExtensions: []string{".rs"},
+		Comments:         nestedCStyleComments,
+		FenceTag:         "rust",
+	},
+	{
+		Name:  "PHP",
+		Flags: []string{"-php"},
+		Extensions: []string{
+			".php", ".phtml", ".php3", ".php4", ".php5", ".php7", ".phps", ".ini",
+			".json", ".xml", ".yaml", ".yml", ".toml", ".lock", ".md", ".txt",
+		},
+		Interpreters:     []string{"php"},
+		Manifests:        []string{"composer.json"},
+		SourceExtensions: []string{".php"},
+		Comments: Comments{
+			Line:  []string{"//", "#"},
+			Block: []BlockComment{{Start: "/*", End: "*/"}},
+			Strings: []StringLiteral{
+				{Delimiter: `"`, Multiline: true},
+				{Delimiter: `'`, Multiline: true},
+				{Delimiter: "`", Multiline: true},
+			},
+			Heredocs: true,
+		},
+		FenceTag: "php",
+	},
+	{
+		Name:  "Java",
+		Flags: []string{"-java"},
+		Extensions: []string{
+			".java", ".class", ".jar", ".xml", ".json", ".yaml", ".yml", ".toml",
+			".md", ".txt",
+		},
+		Manifests:        []string{"pom.xml", "build.gradle"},
+		SourceExtensions: []string{".java"},
+		Comments:         cStyleComments,
+		FenceTag:         "java",
+	},
+	{
+		Name:  "Ruby",
+		Flags: []string{"-rb"},
+		Extensions: []string{
+			".rb", ".rbw", ".rake", ".gemspec", ".ru", ".erb", ".yml", ".yaml",
+			".json", ".toml", ".lock", ".md", ".txt",
+		},
+		Filenames:        []string{"Gemfile", "Rakefile"},
+		Interpreters:     []string{"ruby", "jruby"},
+		Manifests:        []string{"Gemfile"},
+		SourceExtensions: []string{".rb"},
+		Comments: Comments{
+			Line:  []string{"#"},
+			Block: []BlockComment{{Start: "=begin", End: "=end"}},
+			Strings: []StringLiteral{
+				{Delimiter: `"`, Multiline: true},
+				{Delimiter: `'`, Multiline: true},
+				{Delimiter: "`", Multiline: true},
+			},
+			Heredocs: true,
+		},
+		FenceTag: "ruby",
+	},
+	{
+		Name:  "C#",
+		Flags: []string{"-cs"},
+		Extensions: []string{
+			".cs", ".csx", ".sln", ".csproj", ".vbproj", ".xml", ".json", ".yaml",
+			".yml", ".toml", ".md", ".txt",
+		},
+		Manifests:        []string{"*.csproj", "*.sln"},
+		SourceExtensions: []string{".cs"},
+		Comments:         cStyleComments,
+		FenceTag:         "csharp",
+	},
+	{
+		Name:  "C/C++",
+		Flags: []string{"-cpp", "-c"},
+		Extensions: []string{
+			".c", ".h", ".cc", ".cpp", ".cxx", ".c++", ".hh", ".hpp", ".hxx", ".h++", ".ipp",
+			".inl", ".tpp", ".cmake", ".yaml", ".yml", ".json", ".md", ".txt",
+		},
+		Filenames:        []string{"CMakeLists.txt", "meson.build", "conanfile.txt", "vcpkg.json"},
+		Manifests:        []string{"CMakeLists.txt", "meson.build", "conanfile.txt", "conanfile.py", "vcpkg.json"},
+		SourceExtensions: []string{".c", ".cc", ".cpp", ".cxx", ".h", ".hpp"},
+		Comments:         cStyleComments,
+		FenceTag:         "cpp",
+	},
+	{
+		Name:  "Kotlin",
+		Flags: []string{"-kt"},
+		Extensions: []string{
+			".kt", ".kts", ".gradle", ".properties", ".xml", ".json", ".yaml", ".yml",
+			".toml", ".md", ".txt",
+		},
+		Interpreters:     []string{"kotlin", "kscript"},
+		Manifests:        []string{"build.gradle.kts", "settings.gradle.kts"},
+		SourceExtensions: []string{".kt", ".kts"},
+		Comments:         nestedCStyleComments,
+		FenceTag:         "kotlin",
+	},
+	{
+		Name:  "Swift",
+		Flags: []string{"-swift"},
+		Extensions: []string{
+			".swift", ".plist", ".xcconfig", ".json", ".yaml", ".yml", ".md", ".txt",
+		},
+		Interpreters:     []string{"swift"},
+		Manifests:        []string{"Package.swift"},
+		SourceExtensions: []string{".swift"},
+		Comments:         nestedCStyleComments,
+		FenceTag:         "swift",
+	},
+	{
+		Name:  "Scala",
+		Flags: []string{"-scala"},
+		Extensions: []string{
+			".scala", ".sc", ".sbt", ".conf", ".properties", ".json", ".yaml", ".yml",
+			".md", ".txt",
+		},
+		Interpreters:      []string{"scala", "amm"},
+		Manifests:         []string{"build.sbt", "build.sc"},
+		SourceExtensions:  []string{".scala", ".sc"},
+		Comments:          nestedCStyleComments,
+		FenceTag:          "scala",
+		SignificantIndent: true,
+	},
+	{
+		Name:  "Elixir",
+		Flags: []string{"-ex"},
+		Extensions: []string{
+			".ex", ".exs", ".eex", ".heex", ".leex", ".json", ".yaml", ".yml", ".md", ".txt",
+		},
+		Interpreters:     []string{"elixir"},
+		Manifests:        []string{"mix.exs"},
+		SourceExtensions: []string{".ex", ".exs"},
+		Comments:         hashComments,
+		FenceTag:         "elixir",
+	},
+	{
+		Name:  "Haskell",
+		Flags: []string{"-hs"},
+		Extensions: []string{
+			".hs", ".lhs", ".hsc", ".cabal", ".yaml", ".yml", ".json", ".md", ".txt",
+		},
+		Filenames:        []string{"cabal.project"},
+		Interpreters:     []string{"runhaskell", "runghc", "stack"},
+		Manifests:        []string{"*.cabal", "cabal.project", "stack.yaml"},
+		SourceExtensions: []string{".hs", ".lhs"},
+		Comments: Comments{
+			Line:   []string{"--"},
+			Block:  []BlockComment{{Start: "{-", End: "-}"}},
+			Nested: true,
+		},
+		FenceTag:          "haskell",
+		SignificantIndent: true,
+	},
+	{
+		Name:  "Zig",
+		Flags: []string{"-zig"},
+		Extensions: []string{
+			".zig", ".zon", ".json", ".md", ".txt",
+		},
+		Manifests:        []string{"build.zig"},
+		SourceExtensions: []string{".zig"},
+		Comments: Comments{
+			Line: []string{"//"},
+		},
+		FenceTag: "zig",
+	},
+	{
+		Name:  "Lua",
+		Flags: []string{"-lua"},
+		Extensions: []string{
+			".lua", ".rockspec", ".json", ".yaml", ".yml", ".md", ".txt",
+		},
+		Filenames:        []string{".luarc.json"},
+		Interpreters:     []string{"lua", "luajit"},
+		Manifests:        []string{"*.rockspec", ".luarc.json"},
+		SourceExtensions: []string{".lua"},
+		Comments: Comments{
+			Line:  []string{"--"},
+			Block: []BlockComment{{Start: "--[[", End: "]]"}},
+		},
+		FenceTag: "lua",
+	},
+	{
+		Name:  "Dart",
+		Flags: []string{"-dart"},
+		Extensions: []string{
+			".dart", ".arb", ".yaml", ".yml", ".json", ".lock", ".md", ".txt",
+		},
+		Interpreters:     []string{"dart"},
+		Manifests:        []string{"pubspec.yaml"},
+		SourceExtensions: []string{".dart"},
+		Comments:         nestedCStyleComments,
+		FenceTag:         "dart",
+	},
+	{
+		Name:  "Shell",
+		Flags: []string{"-sh"},
+		Extensions: []string{
+			".sh", ".bash", ".zsh", ".ksh", ".fish", ".md", ".txt",
+		},
+		Interpreters:     []string{"sh", "bash", "zsh", "ksh", "dash", "fish"},
+		SourceExtensions: []string{".sh", ".bash", ".zsh"},
+		Comments:         shellComments,
+		FenceTag:         "bash",
+	},
+	{
+		Name:  "Terraform",
+		Flags: []string{"-tf"},
+		Extensions: []string{
+			".tf", ".tfvars", ".hcl", ".tftpl", ".json", ".yaml", ".yml", ".md", ".txt",
+		},
+		Manifests:        []string{"*.tf", "terragrunt.hcl"},
+		SourceExtensions: []string{".tf"},
+		Comments: Comments{
+			Line:     []string{"#", "//"},
+			Block:    []BlockComment{{Start: "/*", End: "*/"}},
+			Heredocs: true,
+		},
+		FenceTag: "hcl",
+	},
+}
@@ -0,0 +1,232 @@
+package languages_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"codecopy/helpers"
+	"codecopy/languages"
+)
+
+// writeTree creates the given files, relative to a new temporary root, and
+// returns the root.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// relativePaths returns paths relative to root with forward slashes, sorted.
+func relativePaths(t *testing.T, root string, paths []string) []string {
+	t.Helper()
+	rel := make([]string, 0, len(paths))
+	for _, path := range paths {
+		r, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	return rel
+}
+
+// describe returns each project as "Language" or "Language (dir)", sorted.
+func describe(t *testing.T, root string, projects []helpers.Project) []string {
+	t.Helper()
+	var described []string
+	for _, project := range projects {
+		dir := relativePaths(t, root, []string{project.Root})[0]
+		if dir == "." {
+			described = append(described, project.Language)
+		} else {
+			described = append(described, project.Language+" ("+dir+")")
+		}
+	}
+	sort.Strings(described)
+	return described
+}
+
+func TestDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		projects []string
+		relevant []string
+	}{
+		{
+			name:     "c with cmake",
+			files:    map[string]string{"CMakeLists.txt": "project(x)\n", "src/main.c": "int main() {}\n", "include/x.h": "", "logo.png": ""},
+			projects: []string{"C/C++"},
+			relevant: []string{"CMakeLists.txt", "include/x.h", "src/main.c"},
+		},
+		{
+			name:     "kotlin gradle with java sources",
+			files:    map[string]string{"build.gradle.kts": "", "settings.gradle.kts": "", "src/Main.kt": "fun main() {}\n", "src/Legacy.java": "", "gradlew.bat": ""},
+			projects: []string{"Java", "Kotlin"},
+			relevant: []string{"build.gradle.kts", "settings.gradle.kts", "src/Legacy.java", "src/Main.kt"},
+		},
+		{
+			name:     "swift package",
+			files:    map[string]string{"Package.swift": "", "Sources/App/main.swift": "print(1)\n", "README.rst": ""},
+			projects: []string{"Swift"},
+			relevant: []string{"Package.swift", "Sources/App/main.swift"},
+		},
+		{
+			name:     "scala sbt",
+			files:    map[string]string{"build.sbt": "", "src/main/scala/Main.scala": "", "application.conf": ""},
+			projects: []string{"Scala"},
+			relevant: []string{"application.conf", "build.sbt", "src/main/scala/Main.scala"},
+		},
+		{
+			name:     "elixir mix",
+			files:    map[string]string{"mix.exs": "", "lib/app.ex": "", "test/app_test.exs": "", "priv/app.erl": ""},
+			projects: []string{"Elixir"},
+			relevant: []string{"lib/app.ex", "mix.exs", "test/app_test.exs"},
+		},
+		{
+			name:     "haskell cabal",
+			files:    map[string]string{"example.cabal": "", "src/Main.hs": "main = pure ()\n"},
+			projects: []string{"Haskell"},
+			relevant: []string{"example.cabal", "src/Main.hs"},
+		},
+		{
+			name:     "zig",
+			files:    map[string]string{"build.zig": "", "build.zig.zon": "", "src/main.zig": ""},
+			projects: []string{"Zig"},
+			relevant: []string{"build.zig", "build.zig.zon", "src/main.zig"},
+		},
+		{
+			name:     "lua rockspec",
+			files:    map[string]string{"example-1.0-1.rockspec": "", "init.lua": "", "init.vim": ""},
+			projects: []string{"Lua"},
+			relevant: []string{"example-1.0-1.rockspec", "init.lua"},
+		},
+		{
+			name:     "dart pubspec",
+			files:    map[string]string{"pubspec.yaml": "", "lib/main.dart": "", "l10n/app_en.arb": ""},
+			projects: []string{"Dart"},
+			relevant: []string{"l10n/app_en.arb", "lib/main.dart", "pubspec.yaml"},
+		},
+		{
+			name:     "terraform",
+			files:    map[string]string{"main.tf": "", "prod.tfvars": "", "user_data.tftpl": ""},
+			projects: []string{"Terraform"},
+			relevant: []string{"main.tf", "prod.tfvars", "user_data.tftpl"},
+		},
+		{
+			name: "shebang scripts",
+			files: map[string]string{
+				"go.mod":           "module example\n",
+				"main.go":          "package main\n",
+				"scripts/deploy":   "#!/usr/bin/env bash\necho deploy\n",
+				"scripts/serve":    "#!/usr/bin/env -S lua5.4 -W\nprint(1)\n",
+				"scripts/notes":    "remember to deploy\n",
+				"scripts/unknown":  "#!/opt/custom/interpreter\n",
+				"node_modules/x":   "#!/bin/sh\n",
+				"Dockerfile":       "FROM scratch\n",
+				"docs/design.pdf":  "",
+				"scripts/setup.sh": "echo setup\n",
+			},
+			projects: []string{"Go", "Shell"},
+			relevant: []string{"Dockerfile", "go.mod", "main.go", "scripts/deploy", "scripts/serve", "scripts/setup.sh"},
+		},
+		{
+			name: "mixed repository",
+			files: map[string]string{
+				"services/api/go.mod":      "module api\n",
+				"services/api/main.go":     "package main\n",
+				"web/package.json":         "{}\n",
+				"web/src/index.ts":         "export {}\n",
+				"crates/core/Cargo.toml":   "[package]\n",
+				"crates/core/src/lib.rs":   "",
+				"web/assets/logo.png":      "",
+				"services/api/notes.txt":   "",
+				"services/api/config.yaml": "",
+			},
+			projects: []string{"Go (services/api)", "JavaScript/TypeScript (web)", "Rust (crates/core)"},
+			relevant: []string{"crates/core/Cargo.toml", "crates/core/src/lib.rs", "services/api/config.yaml", "services/api/go.mod", "services/api/main.go", "services/api/notes.txt", "web/package.json", "web/src/index.ts"},
+		},
+		{
+			name: "no manifest",
+			files: map[string]string{
+				"a.py":      "print(1)\n",
+				"b.py":      "print(2)\n",
+				"tool.rb":   "puts 1\n",
+				"notes.txt": "",
+			},
+			projects: []string{"Python"},
+			relevant: []string{"a.py", "b.py", "notes.txt"},
+		},
+		{
+			name: "source files outside every project",
+			files: map[string]string{
+				"web/package.json": "{}\n",
+				"web/index.js":     "console.log(1)\n",
+				"scripts/a.py":     "print(1)\n",
+				"scripts/b.py":     "print(2)\n",
+				"docs/notes.md":    "# Notes\n",
+			},
+			projects: []string{"JavaScript/TypeScript (web)", "Python"},
+			relevant: []string{"scripts/a.py", "scripts/b.py", "web/index.js", "web/package.json"},
+		},
+	}
+
+	registry := languages.Default()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+
+			projects, err := helpers.DetectProjects(root, registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(t, root, projects); !reflect.DeepEqual(got, tt.projects) {
+				t.Errorf("projects = %v, want %v", got, tt.projects)
+			}
+
+			relevant, err := helpers.GetRelevantFiles(root, registry, projects, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relativePaths(t, root, relevant); !reflect.DeepEqual(got, tt.relevant) {
+				t.Errorf("relevant files = %v, want %v", got, tt.relevant)
+			}
+		})
+	}
+}
+
+func TestDetectionSelectedLanguage(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":       "module example\n",
+		"main.go":      "package main\n",
+		"init.lua":     "print(1)\n",
+		"plugin/x.lua": "print(2)\n",
+		"Makefile":     "all:\n",
+	})
+	registry := languages.Default()
+	projects, err := helpers.DetectProjects(root, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relevant, err := helpers.GetRelevantFiles(root, registry, projects, registry.ByName("Lua"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relativePaths(t, root, relevant), []string{"Makefile", "init.lua", "plugin/x.lua"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("relevant files = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+package languages
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Language describes how codecopy recognizes and renders one language ecosystem.
+type Language struct {
+	// Name is the display name, also used as the project type.
+	Name string `json:"name"`
+	// Flags are the command-line flags that select the language, such as "-go".
+	Flags []string `json:"flags,omitempty"`
+	// Extensions are the file extensions included for the language's projects.
+	Extensions []string `json:"extensions,omitempty"`
+	// Filenames are file names or name patterns included regardless of extension, such as "Rakefile".
+	Filenames []string `json:"filenames,omitempty"`
+	// Interpreters are the shebang interpreters that identify the language's extensionless scripts.
+	Interpreters []string `json:"interpreters,omitempty"`
+	// Manifests are file name patterns that mark the root of a project.
+	Manifests []string `json:"manifests,omitempty"`
+	// SourceExtensions identify the language's source files when no manifest is present.
+	SourceExtensions []string `json:"source_extensions,omitempty"`
+	// Comments describes the language's comment syntax.
+	Comments Comments `json:"comments,omitempty"`
+	// FenceTag is the info string used for fenced code blocks.
+	FenceTag string `json:"fence_tag,omitempty"`
+	// SignificantIndent reports whether indentation is part of the syntax,
+	// as in Python, so minification must not change it.
+	SignificantIndent bool `json:"significant_indent,omitempty"`
+}
+
+// Comments describes the comment syntax of a language, along with the string
+// literals and here-documents in which comment markers are not comments.
+type Comments struct {
+	Line   []string       `json:"line,omitempty"`
+	Block  []BlockComment `json:"block,omitempty"`
+	Nested bool           `json:"nested,omitempty"`
+	// Strings are the string literal delimiters; nil means double and single
+	// quotes ending at a line break, plus triple quotes and backticks that
+	// may span lines.
+	Strings []StringLiteral `json:"strings,omitempty"`
+	// Heredocs reports whether "<<WORD" starts a here-document that runs
+	// until a line holding only WORD.
+	Heredocs bool `json:"heredocs,omitempty"`
+}
+
+// StringLiteral describes one kind of string literal.
+type StringLiteral struct {
+	Delimiter string `json:"delimiter"`
+	// Multiline literals may span lines; others end at a line break.
+	Multiline bool `json:"multiline,omitempty"`
+	// Raw literals have no backslash escapes.
+	Raw bool `json:"raw,omitempty"`
+}
+
+// BlockComment is a pair of block comment delimiters.
+type BlockComment struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// IsZero reports whether no comment syntax is defined.
+func (c Comments) IsZero() bool {
+	return len(c.Line) == 0 && len(c.Block) == 0
+}
+
+// IncludesFile reports whether a file with the given path belongs to the language's file set.
+func (l *Language) IncludesFile(path string) bool {
+	return matchesFilename(l.Filenames, path) || containsFold(l.Extensions, filepath.Ext(path))
+}
+
+// IsManifest reports whether the file name marks the root of one of the language's projects.
+func (l *Language) IsManifest(name string) bool {
+	for _, pattern := range l.Manifests {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry is an ordered set of languages.
+type Registry struct {
+	languages []*Language
+	// commonFilenames are build and deployment files relevant to every project.
+	commonFilenames []string
+}
+
+// NewRegistry creates a registry holding copies of the given languages.
+func NewRegistry(languages []Language) *Registry {
+	registry := &Registry{}
+	for _, language := range languages {
+		language := language
+		registry.languages = append(registry.languages, &language)
+	}
+	return registry
+}
+
+// Default returns a registry of the built-in languages and common files.
+func Default() *Registry {
+	registry := NewRegistry(builtinLanguages)
+	registry.AddCommonFilenames(builtinCommonFilenames)
+	return registry
+}
+
+// AddCommonFilenames adds file names or name patterns that are relevant to every project.
+func (r *Registry) AddCommonFilenames(filenames []string) {
+	r.commonFilenames = append(r.commonFilenames, filenames...)
+}
+
+// IsCommonFile reports whether the path is a build or deployment file relevant to every project.
+func (r *Registry) IsCommonFile(path string) bool {
+	return matchesFilename(r.commonFilenames, path)
+}
+
+// Languages returns the registered languages in order.
+func (r *Registry) Languages() []*Language {
+	return r.languages
+}
+
+// ByName returns the language with the given name, or nil.
+func (r *Registry) ByName(name string) *Language {
+	for _, language := range r.languages {
+		if strings.EqualFold(language.Name, name) {
+			return language
+		}
+	}
+	return nil
+}
+
+// ByFlag returns the language selected by a command-line flag, or nil.
+func (r *Registry) ByFlag(flag string) *Language {
+	for _, language := range r.languages {
+		for _, languageFlag := range language.Flags {
+			if languageFlag == flag {
+				return language
+			}
+		}
+	}
+	return nil
+}
+
+// ByManifest returns the language whose projects are rooted by the given manifest file name, or nil.
+func (r *Registry) ByManifest(name string) *Language {
+	for _, language := range r.languages {
+		if language.IsManifest(name) {
+			return language
+		}
+	}
+	return nil
+}
+
+// BySourceExtension returns the language whose source files use the extension, or nil.
+func (r *Registry) BySourceExtension(ext string) *Language {
+	for _, language := range r.languages {
+		if containsFold(language.SourceExtensions, ext) {
+			return language
+		}
+	}
+	return nil
+}
+
+// ByFile returns the language whose source files or special file names match the path, or nil.
+func (r *Registry) ByFile(path string) *Language {
+	if language := r.BySourceExtension(filepath.Ext(path)); language != nil {
+		return language
+	}
+	for _, language := range r.languages {
+		if matchesFilename(language.Filenames, path) {
+			return language
+		}
+	}
+	return nil
+}
+
+// ByInterpreter returns the language run by a shebang interpreter such as
+// "python3" or "bash", or nil. Version suffixes are ignored.
+func (r *Registry) ByInterpreter(interpreter string) *Language {
+	interpreter = strings.TrimRight(filepath.Base(interpreter), "0123456789.")
+	for _, language := range r.languages {
+		if containsFold(language.Interpreters, interpreter) {
+			return language
+		}
+	}
+	return nil
+}
+
+// Selected returns the language chosen by the first language flag in args, or nil.
+func (r *Registry) Selected(args []string) *Language {
+	for _, arg := range args {
+		if language := r.ByFlag(arg); language != nil {
+			return language
+		}
+	}
+	return nil
+}
+
+// Merge adds the given languages to the registry. A language whose name is
+// already registered overrides the fields it sets; any other is appended.
+func (r *Registry) Merge(languages []Language) {
+	for _, language := range languages {
+		existing := r.ByName(language.Name)
+		if existing == nil {
+			language := language
+			r.languages = append(r.languages, &language)
+			continue
+		}
+
+		if language.Flags != nil {
+			existing.Flags = language.Flags
+		}
+		if language.Extensions != nil {
+			existing.Extensions = language.Extensions
+		}
+		if language.Filenames != nil {
+			existing.Filenames = language.Filenames
+		}
+		if language.Interpreters != nil {
+			existing.Interpreters = language.Interpreters
+		}
+		if language.Manifests != nil {
+			existing.Manifests = language.Manifests
+		}
+		if language.SourceExtensions != nil {
+			existing.SourceExtensions = language.SourceExtensions
+		}
+		if !language.Comments.IsZero() {
+			existing.Comments = language.Comments
+		}
+		if language.FenceTag != "" {
+			existing.FenceTag = language.FenceTag
+		}
+		if language.SignificantIndent {
+			existing.SignificantIndent = true
+		}
+	}
+}
+
+// matchesFilename reports whether the base name of path matches any of the file name patterns.
+func matchesFilename(patterns []string, path string) bool {
+	name := filepath.Base(path)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold checks if a string is present in a slice, ignoring case.
+func containsFold(slice []string, item string) bool {
+	for _, val := range slice {
+		if strings.EqualFold(val, item) {
+			return true
+		}
+	}
+	return false
+}
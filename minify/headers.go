@@ -0,0 +1,109 @@
+package minify
+
+import (
+	"fmt"
+	"strings"
+
+	"codecopy/languages"
+)
+
+// minHeaderFiles is the number of files that must share a leading license
+// comment before it is treated as boilerplate. Two files alike, such as a
+// pair of generated siblings, are more often a coincidence than a header.
+const minHeaderFiles = 3
+
+// Header is a leading license comment shared by several files.
+type Header struct {
+	ID    int
+	Text  string
+	Files []string
+}
+
+// Marker returns the text that replaces the header in each file that has it.
+func (h Header) Marker() string {
+	return fmt.Sprintf("[shared header %d]", h.ID)
+}
+
+// LeadingComment returns the byte range of the comment block at the top of
+// content, after any shebang line. ok is false if the content does not start
+// with a comment.
+func LeadingComment(content string, comments languages.Comments) (start, end int, ok bool) {
+	if comments.IsZero() {
+		return 0, 0, false
+	}
+
+	found := Scan(content, comments)
+	if len(found) == 0 || !found[0].FullLine {
+		return 0, 0, false
+	}
+	if strings.TrimSpace(stripShebang(content[:found[0].Start])) != "" {
+		return 0, 0, false
+	}
+
+	last := found[groupEnd(content, found, 0)-1]
+	return found[0].Start, last.End, true
+}
+
+// DeduplicateHeaders finds leading license comments that repeat across files
+// and replaces each occurrence with the header's marker. contents maps each
+// file to its content and is updated in place; files gives the order in which
+// headers are numbered. Headers are compared ignoring trailing whitespace and
+// are only replaced where the marker is shorter than the header. Other
+// leading comments, such as package docs or directives, are left alone.
+func DeduplicateHeaders(files []string, contents map[string]string, commentsFor func(file string) languages.Comments) []Header {
+	type occurrence struct {
+		file       string
+		start, end int
+	}
+
+	var order []string
+	occurrences := make(map[string][]occurrence)
+	for _, file := range files {
+		content, ok := contents[file]
+		if !ok {
+			continue
+		}
+		start, end, ok := LeadingComment(content, commentsFor(file))
+		if !ok {
+			continue
+		}
+		key := normalizeHeader(content[start:end])
+		if !licensePattern.MatchString(key) {
+			continue
+		}
+		if _, seen := occurrences[key]; !seen {
+			order = append(order, key)
+		}
+		occurrences[key] = append(occurrences[key], occurrence{file: file, start: start, end: end})
+	}
+
+	var headers []Header
+	for _, key := range order {
+		found := occurrences[key]
+		if len(found) < minHeaderFiles {
+			continue
+		}
+
+		header := Header{ID: len(headers) + 1, Text: key}
+		if len(header.Marker()) >= len(key) {
+			continue
+		}
+		for _, occurrence := range found {
+			content := contents[occurrence.file]
+			contents[occurrence.file] = content[:occurrence.start] + header.Marker() + content[occurrence.end:]
+			header.Files = append(header.Files, occurrence.file)
+		}
+		headers = append(headers, header)
+	}
+
+	return headers
+}
+
+// normalizeHeader trims trailing whitespace from each line of a header.
+func normalizeHeader(header string) string {
+	lines := strings.Split(header, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
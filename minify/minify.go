@@ -0,0 +1,597 @@
+package minify
+
+import (
+	"regexp"
+	"strings"
+
+	"codecopy/languages"
+)
+
+// Options selects which parts of a file are removed.
+type Options struct {
+	// StripLicense removes a license or copyright comment at the top of the file.
+	StripLicense bool
+	// StripDoc removes doc comments: comments in doc syntax such as "///" or
+	// "/**", and comment lines directly preceding code.
+	StripDoc bool
+	// StripInline removes all other comments.
+	StripInline bool
+	// CollapseBlankLines reduces runs of blank lines to a single one and
+	// trims trailing whitespace.
+	CollapseBlankLines bool
+	// NormalizeIndent reduces space indentation to one space per level. It
+	// must not be set for languages where indentation is significant.
+	NormalizeIndent bool
+}
+
+// All returns options that enable every reduction.
+func All() Options {
+	return Options{
+		StripLicense:       true,
+		StripDoc:           true,
+		StripInline:        true,
+		CollapseBlankLines: true,
+		NormalizeIndent:    true,
+	}
+}
+
+// Kind classifies a comment.
+type Kind int
+
+const (
+	Inline Kind = iota
+	Doc
+	License
+	// Directive marks a comment that instructs a compiler or interpreter,
+	// such as a Go build constraint or a Python encoding declaration. It is
+	// never removed.
+	Directive
+)
+
+// Comment is a comment found in source code, as a byte range.
+type Comment struct {
+	Start, End int
+	// FullLine reports whether the comment is the only thing on its lines.
+	FullLine bool
+	Kind     Kind
+}
+
+var (
+	licensePattern   = regexp.MustCompile(`(?i)copyright|licen[cs]e|spdx-license-identifier|all rights reserved`)
+	docCommentPrefix = []string{"///", "//!", "/**", "/*!", "--|", "-- |", "{-|", "##"}
+	// directivePattern matches Go build constraints, compiler directives and
+	// cgo exports, and Python type comments.
+	directivePattern = regexp.MustCompile(`^(//go:\S|//\s*\+build\s|//export\s|//line\s|#\s*type:)`)
+	// codingPattern matches a Python encoding declaration, which only counts
+	// on the first two lines of a file.
+	codingPattern = regexp.MustCompile(`^#.*coding[:=]\s*[-\w.]+`)
+	// cgoImport is the import whose preceding comment is C code for cgo.
+	cgoImport = regexp.MustCompile(`^import\s+"C"\s*$`)
+)
+
+// Minify applies the selected reductions to content written in a language
+// with the given comment syntax. Content without comment syntax is in a
+// language minify knows nothing about and is returned unchanged.
+func Minify(content string, comments languages.Comments, opts Options) string {
+	if comments.IsZero() {
+		return content
+	}
+
+	if opts.StripLicense || opts.StripDoc || opts.StripInline {
+		var remove []Comment
+		for _, comment := range Classify(content, comments) {
+			switch {
+			case comment.Kind == License && opts.StripLicense,
+				comment.Kind == Doc && opts.StripDoc,
+				comment.Kind == Inline && opts.StripInline:
+				remove = append(remove, comment)
+			}
+		}
+		content = removeComments(content, remove)
+	}
+
+	if opts.CollapseBlankLines {
+		content = collapseBlankLines(content)
+	}
+	if opts.NormalizeIndent {
+		content = normalizeIndent(content, comments)
+	}
+	return content
+}
+
+// Classify finds the comments in content and labels each as a license header,
+// a doc comment, an inline comment or a directive.
+func Classify(content string, comments languages.Comments) []Comment {
+	found := Scan(content, comments)
+
+	for i := range found {
+		text := content[found[i].Start:found[i].End]
+		if hasAnyPrefix(text, docCommentPrefix) {
+			found[i].Kind = Doc
+		}
+	}
+
+	for start := 0; start < len(found); {
+		end := groupEnd(content, found, start)
+		group := found[start:end]
+		groupText := content[group[0].Start:group[len(group)-1].End]
+		before := strings.TrimSpace(stripShebang(content[:group[0].Start]))
+		after := content[group[len(group)-1].End:]
+
+		switch {
+		case group[0].FullLine && importsC(after):
+			setKind(group, Directive)
+		case before == "" && group[0].FullLine && licensePattern.MatchString(groupText):
+			setKind(group, License)
+		case group[0].FullLine && precedesCode(after):
+			setKind(group, Doc)
+		}
+		start = end
+	}
+
+	for i := range found {
+		if isDirective(content, found[i]) {
+			found[i].Kind = Directive
+		}
+	}
+
+	return found
+}
+
+// isDirective reports whether comment is a directive: a shebang line, a Go or
+// Python directive, or an encoding declaration on the first two lines.
+func isDirective(content string, comment Comment) bool {
+	text := content[comment.Start:comment.End]
+	switch {
+	case comment.Start == 0 && strings.HasPrefix(text, "#!"):
+		return true
+	case directivePattern.MatchString(text):
+		return true
+	default:
+		return codingPattern.MatchString(text) && strings.Count(content[:comment.Start], "\n") < 2
+	}
+}
+
+// importsC reports whether the text after a comment continues with
+// import "C" on the very next line, making the comment cgo's C preamble.
+func importsC(after string) bool {
+	nl := strings.IndexByte(after, '\n')
+	if nl < 0 {
+		return false
+	}
+	next := after[nl+1:]
+	if end := strings.IndexByte(next, '\n'); end >= 0 {
+		next = next[:end]
+	}
+	return cgoImport.MatchString(strings.TrimSpace(next))
+}
+
+// defaultStrings are the string literals of languages that list none of their own.
+var defaultStrings = []languages.StringLiteral{
+	{Delimiter: `"""`, Multiline: true},
+	{Delimiter: `'''`, Multiline: true},
+	{Delimiter: "`", Multiline: true, Raw: true},
+	{Delimiter: `"`},
+	{Delimiter: `'`},
+}
+
+// Scan returns the comments in content, skipping comment markers inside
+// string literals and here-documents.
+func Scan(content string, comments languages.Comments) []Comment {
+	found, _ := scan(content, comments)
+	return found
+}
+
+// span is a byte range of content.
+type span struct {
+	start, end int
+}
+
+// scan returns the comments in content along with the byte ranges of its
+// string literals and here-document bodies, both in order.
+func scan(content string, comments languages.Comments) ([]Comment, []span) {
+	literals := comments.Strings
+	if literals == nil {
+		literals = defaultStrings
+	}
+
+	var found []Comment
+	var strs []span
+	var heredocs []string
+
+	for i := 0; i < len(content); {
+		if content[i] == '\\' {
+			i += 2
+			continue
+		}
+
+		if content[i] == '\n' && len(heredocs) > 0 {
+			end := skipHeredocs(content, i+1, heredocs)
+			strs = append(strs, span{i, end})
+			i = end
+			heredocs = nil
+			continue
+		}
+
+		if comments.Heredocs {
+			if word, end, ok := matchHeredoc(content, i); ok {
+				heredocs = append(heredocs, word)
+				i = end
+				continue
+			}
+		}
+
+		if literal, ok := matchString(content[i:], literals); ok {
+			end := skipString(content, i, literal)
+			strs = append(strs, span{i, end})
+			i = end
+			continue
+		}
+
+		if block, ok := matchBlockStart(content[i:], comments.Block); ok {
+			end := skipBlockComment(content, i, block, comments.Nested)
+			found = append(found, Comment{Start: i, End: end, FullLine: isFullLine(content, i, end)})
+			i = end
+			continue
+		}
+
+		if marker, ok := matchLineComment(content, i, comments.Line); ok {
+			end := strings.IndexByte(content[i+len(marker):], '\n')
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i + len(marker)
+			}
+			found = append(found, Comment{Start: i, End: end, FullLine: isFullLine(content, i, end)})
+			i = end
+			continue
+		}
+
+		i++
+	}
+
+	return found, strs
+}
+
+// groupEnd returns the index just past the group of comments starting at
+// found[start]. A run of full-line comments separated only by line breaks
+// forms a group.
+func groupEnd(content string, found []Comment, start int) int {
+	end := start + 1
+	for end < len(found) && found[end].FullLine && found[end-1].FullLine &&
+		strings.Count(content[found[end-1].End:found[end].Start], "\n") <= 1 &&
+		strings.TrimSpace(content[found[end-1].End:found[end].Start]) == "" {
+		end++
+	}
+	return end
+}
+
+// matchString returns the string literal whose delimiter begins s. Longer
+// delimiters come first, so triple quotes win over single ones.
+func matchString(s string, literals []languages.StringLiteral) (languages.StringLiteral, bool) {
+	for _, literal := range literals {
+		if strings.HasPrefix(s, literal.Delimiter) {
+			return literal, true
+		}
+	}
+	return languages.StringLiteral{}, false
+}
+
+// skipString returns the offset just past the string literal starting at i.
+// Literals that cannot span lines end at a line break, so apostrophes in
+// languages that use them for other purposes (such as Rust lifetimes) do not
+// swallow the rest of the file.
+func skipString(content string, i int, literal languages.StringLiteral) int {
+	quote := literal.Delimiter
+	j := i + len(quote)
+	for j < len(content) {
+		switch {
+		case content[j] == '\\' && !literal.Raw:
+			j += 2
+		case strings.HasPrefix(content[j:], quote):
+			return j + len(quote)
+		case content[j] == '\n' && !literal.Multiline:
+			return i + 1
+		default:
+			j++
+		}
+	}
+	if !literal.Multiline {
+		return i + 1
+	}
+	return len(content)
+}
+
+// matchHeredoc recognizes the start of a here-document such as <<EOF,
+// <<-'EOF', <<~EOS or PHP's <<<EOT at offset i, returning its terminating
+// word and the offset just past it. A bare word after a space must be upper
+// case, so Ruby's "list << item" is not mistaken for one.
+func matchHeredoc(content string, i int) (string, int, bool) {
+	if !strings.HasPrefix(content[i:], "<<") {
+		return "", 0, false
+	}
+	j := i + 2
+	spaced := false
+	if j < len(content) && content[j] == '<' {
+		j++
+	} else {
+		if j < len(content) && (content[j] == '-' || content[j] == '~') {
+			j++
+		}
+		for j < len(content) && (content[j] == ' ' || content[j] == '\t') {
+			j++
+			spaced = true
+		}
+	}
+
+	var quote byte
+	if j < len(content) && (content[j] == '\'' || content[j] == '"') {
+		quote = content[j]
+		j++
+	}
+	start := j
+	if j >= len(content) || !isWordStart(content[j]) {
+		return "", 0, false
+	}
+	for j < len(content) && isWordChar(content[j]) {
+		j++
+	}
+	word := content[start:j]
+	if quote != 0 {
+		if j >= len(content) || content[j] != quote {
+			return "", 0, false
+		}
+		j++
+	} else if spaced && strings.ToUpper(word) != word {
+		return "", 0, false
+	}
+	return word, j, true
+}
+
+// skipHeredocs returns the offset of the line break ending the last of the
+// here-documents whose bodies start at offset start. A body ends at a line
+// holding only its word, possibly indented or followed by ";", "," or ")" as
+// PHP allows.
+func skipHeredocs(content string, start int, words []string) int {
+	i := start
+	for _, word := range words {
+		for i < len(content) {
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i
+			}
+			rest, found := strings.CutPrefix(strings.TrimSpace(content[i:end]), word)
+			if found && (rest == "" || strings.ContainsAny(rest[:1], ";,)")) {
+				i = end
+				break
+			}
+			i = end + 1
+		}
+	}
+	return min(i, len(content))
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9')
+}
+
+// matchBlockStart returns the block comment whose start delimiter begins s.
+func matchBlockStart(s string, blocks []languages.BlockComment) (languages.BlockComment, bool) {
+	for _, block := range blocks {
+		if strings.HasPrefix(s, block.Start) {
+			return block, true
+		}
+	}
+	return languages.BlockComment{}, false
+}
+
+// skipBlockComment returns the offset just past the block comment starting at i.
+func skipBlockComment(content string, i int, block languages.BlockComment, nested bool) int {
+	depth := 1
+	j := i + len(block.Start)
+	for j < len(content) {
+		switch {
+		case strings.HasPrefix(content[j:], block.End):
+			depth--
+			j += len(block.End)
+			if depth == 0 || !nested {
+				return j
+			}
+		case nested && strings.HasPrefix(content[j:], block.Start):
+			depth++
+			j += len(block.Start)
+		default:
+			j++
+		}
+	}
+	return len(content)
+}
+
+// matchLineComment returns the line comment marker starting at offset i. A
+// "#" only starts a comment at the beginning of a line or after whitespace,
+// so shell expressions such as "$#" are not mistaken for comments, and never
+// before "[", where it opens a PHP attribute.
+func matchLineComment(content string, i int, markers []string) (string, bool) {
+	for _, marker := range markers {
+		if !strings.HasPrefix(content[i:], marker) {
+			continue
+		}
+		if marker == "#" && i > 0 && !strings.ContainsRune(" \t\n;", rune(content[i-1])) {
+			continue
+		}
+		if marker == "#" && strings.HasPrefix(content[i:], "#[") {
+			continue
+		}
+		return marker, true
+	}
+	return "", false
+}
+
+// isFullLine reports whether only whitespace surrounds content[start:end] on its lines.
+func isFullLine(content string, start, end int) bool {
+	lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+	if strings.TrimSpace(content[lineStart:start]) != "" {
+		return false
+	}
+	rest := content[end:]
+	if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+		rest = rest[:nl]
+	}
+	return strings.TrimSpace(rest) == ""
+}
+
+// precedesCode reports whether the text after a comment continues with code
+// on the very next line.
+func precedesCode(after string) bool {
+	nl := strings.IndexByte(after, '\n')
+	if nl < 0 {
+		return false
+	}
+	next := after[nl+1:]
+	if end := strings.IndexByte(next, '\n'); end >= 0 {
+		next = next[:end]
+	}
+	return strings.TrimSpace(next) != ""
+}
+
+// removeComments deletes the given comments. Full-line comments take their
+// lines with them; trailing comments take the whitespace before them.
+func removeComments(content string, remove []Comment) string {
+	var out strings.Builder
+	last := 0
+	for _, comment := range remove {
+		start, end := comment.Start, comment.End
+		if comment.FullLine {
+			start = strings.LastIndexByte(content[:start], '\n') + 1
+			if nl := strings.IndexByte(content[end:], '\n'); nl >= 0 {
+				end += nl + 1
+			} else {
+				end = len(content)
+			}
+		} else {
+			for start > last && (content[start-1] == ' ' || content[start-1] == '\t') {
+				start--
+			}
+		}
+		if start < last {
+			continue
+		}
+		out.WriteString(content[last:start])
+		last = end
+	}
+	out.WriteString(content[last:])
+	return out.String()
+}
+
+// collapseBlankLines trims trailing whitespace, reduces runs of blank lines to
+// one and drops blank lines at the start and end.
+func collapseBlankLines(content string) string {
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+// normalizeIndent reduces space indentation to one space per level. The
+// indentation unit is the most common increase in indentation between
+// consecutive lines, so alignment such as the " * " of a block comment does
+// not defeat detection; indentation that is not a whole number of units is
+// rounded down, so a deeper line never ends up shallower than a shallower one.
+// Lines that start inside a string literal or here-document are part of its
+// value and keep their indentation.
+func normalizeIndent(content string, comments languages.Comments) string {
+	lines := strings.Split(content, "\n")
+	inLiteral := literalLines(content, lines, comments)
+
+	steps := make(map[int]int)
+	previous := 0
+	for i, line := range lines {
+		if inLiteral[i] || strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent > previous {
+			steps[indent-previous]++
+		}
+		previous = indent
+	}
+
+	unit := 0
+	for step, count := range steps {
+		if count > steps[unit] || (count == steps[unit] && step < unit) {
+			unit = step
+		}
+	}
+	if unit <= 1 {
+		return content
+	}
+
+	for i, line := range lines {
+		if inLiteral[i] {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		lines[i] = strings.Repeat(" ", indent/unit) + line[indent:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// literalLines reports for each of the lines of content whether it starts
+// inside a string literal or here-document.
+func literalLines(content string, lines []string, comments languages.Comments) []bool {
+	_, literals := scan(content, comments)
+	inLiteral := make([]bool, len(lines))
+	offset, next := 0, 0
+	for i, line := range lines {
+		for next < len(literals) && literals[next].end <= offset {
+			next++
+		}
+		inLiteral[i] = next < len(literals) && literals[next].start < offset
+		offset += len(line) + 1
+	}
+	return inLiteral
+}
+
+// stripShebang removes a leading "#!" line.
+func stripShebang(s string) string {
+	if strings.HasPrefix(s, "#!") {
+		if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+			return s[nl+1:]
+		}
+		return ""
+	}
+	return s
+}
+
+func setKind(comments []Comment, kind Kind) {
+	for i := range comments {
+		comments[i].Kind = kind
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
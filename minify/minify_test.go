@@ -0,0 +1,264 @@
+package minify
+
+import (
+	"strings"
+	"testing"
+
+	"codecopy/languages"
+)
+
+// commentsOf returns the comment syntax of a built-in language.
+func commentsOf(t *testing.T, name string) languages.Comments {
+	t.Helper()
+	language := languages.Default().ByName(name)
+	if language == nil {
+		t.Fatalf("language %q is not registered", name)
+	}
+	return language.Comments
+}
+
+func TestMinifyKeepsDirectives(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     string
+	}{
+		{
+			name:     "go build constraints",
+			language: "Go",
+			content:  "// Copyright 2024 Example. Licensed under MIT.\n\n//go:build linux\n// +build linux\n\npackage p\n",
+			want:     "//go:build linux\n// +build linux\n\npackage p",
+		},
+		{
+			name:     "go compiler directives",
+			language: "Go",
+			content:  "package p\n\n// files holds the templates.\n//go:embed templates/*\nvar files embed.FS\n\n//go:generate stringer -type=Kind\n//go:noinline\nfunc f() {} // plain\n",
+			want:     "package p\n\n//go:embed templates/*\nvar files embed.FS\n\n//go:generate stringer -type=Kind\n//go:noinline\nfunc f() {}",
+		},
+		{
+			name:     "cgo preamble",
+			language: "Go",
+			content:  "package p\n\n// #include <stdio.h>\n// #cgo LDFLAGS: -lm\nimport \"C\"\n\n//export Add\nfunc Add() {}\n",
+			want:     "package p\n\n// #include <stdio.h>\n// #cgo LDFLAGS: -lm\nimport \"C\"\n\n//export Add\nfunc Add() {}",
+		},
+		{
+			name:     "cgo block preamble",
+			language: "Go",
+			content:  "package p\n\n/*\n#include <stdlib.h>\n*/\nimport \"C\"\n",
+			want:     "package p\n\n/*\n#include <stdlib.h>\n*/\nimport \"C\"",
+		},
+		{
+			name:     "python coding and type comments",
+			language: "Python",
+			content:  "#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\n# Helpers.\nx = []  # type: list[int]\ny = 1  # counter\n",
+			want:     "#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\nx = []  # type: list[int]\ny = 1",
+		},
+		{
+			name:     "coding past the second line",
+			language: "Python",
+			content:  "x = 1\ny = 2\n# coding: utf-8\nz = 3\n",
+			want:     "x = 1\ny = 2\nz = 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := All()
+			opts.NormalizeIndent = false
+			if got := Minify(tt.content, commentsOf(t, tt.language), opts); got != tt.want {
+				t.Errorf("Minify =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIndentKeepsLiterals(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     string
+	}{
+		{
+			name:     "go raw string",
+			language: "Go",
+			content:  "func f() {\n    if x {\n        s := `\n    a\n        b\n`\n    }\n}",
+			want:     "func f() {\n if x {\n  s := `\n    a\n        b\n`\n }\n}",
+		},
+		{
+			name:     "template literal",
+			language: "JavaScript/TypeScript",
+			content:  "function f() {\n    if (x) {\n        return `\n    <div>\n        ${x}\n    </div>`;\n    }\n}",
+			want:     "function f() {\n if (x) {\n  return `\n    <div>\n        ${x}\n    </div>`;\n }\n}",
+		},
+		{
+			name:     "heredoc",
+			language: "Shell",
+			content:  "f() {\n    if true; then\n        cat <<EOF\n    keep\n        this\nEOF\n    fi\n}",
+			want:     "f() {\n if true; then\n  cat <<EOF\n    keep\n        this\nEOF\n fi\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeIndent(tt.content, commentsOf(t, tt.language)); got != tt.want {
+				t.Errorf("normalizeIndent =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     string
+	}{
+		{
+			name:     "c-style line and block",
+			language: "Go",
+			content:  "// Copyright 2024 Example.\n\npackage p\n\n/* Add adds. */\nfunc Add(a, b int) int {\n\treturn a + b /* sum */ // done\n}\n",
+			want:     "package p\n\nfunc Add(a, b int) int {\n\treturn a + b\n}",
+		},
+		{
+			name:     "markers inside strings",
+			language: "Go",
+			content:  "package p\n\nvar (\n\turl  = \"http://example.com\" // home\n\traw  = `/* not a comment */`\n\trune = '/'\n)\n",
+			want:     "package p\n\nvar (\n\turl  = \"http://example.com\"\n\traw  = `/* not a comment */`\n\trune = '/'\n)",
+		},
+		{
+			name:     "nested block comments",
+			language: "Rust",
+			content:  "/* outer /* inner */ still outer */\nfn main() {\n    let s = \"/* kept */\";\n}\n",
+			want:     "fn main() {\n    let s = \"/* kept */\";\n}",
+		},
+		{
+			name:     "unnested block comments end at the first close",
+			language: "C/C++",
+			content:  "int a; /* one /* two */ int b;\n",
+			want:     "int a; int b;",
+		},
+		{
+			name:     "doc comments",
+			language: "Rust",
+			content:  "//! Crate docs.\n\n/// Adds.\npub fn add() {}\n",
+			want:     "pub fn add() {}",
+		},
+		{
+			name:     "python indentation and strings",
+			language: "Python",
+			content:  "def f(x):\n    # Check x.\n    if x:\n        return '#'  # hash\n    s = \"\"\"\n    # not a comment\n    \"\"\"\n    return s\n",
+			want:     "def f(x):\n    if x:\n        return '#'\n    s = \"\"\"\n    # not a comment\n    \"\"\"\n    return s",
+		},
+		{
+			name:     "shell heredoc and special parameters",
+			language: "Shell",
+			content:  "#!/bin/sh\n# Usage.\necho $# # count\ncat <<EOF\n# kept\nEOF\necho '# kept' \"# kept\"\n",
+			want:     "#!/bin/sh\necho $#\ncat <<EOF\n# kept\nEOF\necho '# kept' \"# kept\"",
+		},
+		{
+			name:     "ruby block comments",
+			language: "Ruby",
+			content:  "=begin\nNotes.\n=end\nputs \"#{x}\" # show\nlist << item # append\n",
+			want:     "puts \"#{x}\"\nlist << item",
+		},
+		{
+			name:     "haskell nested comments",
+			language: "Haskell",
+			content:  "{- outer {- inner -} outer -}\nmain = putStrLn \"-- kept\" -- greet\n",
+			want:     "main = putStrLn \"-- kept\"",
+		},
+		{
+			name:     "lua long comments",
+			language: "Lua",
+			content:  "--[[ header\nnotes ]]\nlocal x = 1 -- one\n",
+			want:     "local x = 1",
+		},
+		{
+			name:     "php attributes and both line markers",
+			language: "PHP",
+			content:  "<?php\n#[Route('/')]\nfunction f() {} # hash\n$a = 1; // slashes\n",
+			want:     "<?php\n#[Route('/')]\nfunction f() {}\n$a = 1;",
+		},
+		{
+			name:     "unknown syntax is unchanged",
+			language: "",
+			content:  "# title\n\n\n  text  \n",
+			want:     "# title\n\n\n  text  \n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var comments languages.Comments
+			if tt.language != "" {
+				comments = commentsOf(t, tt.language)
+			}
+			opts := All()
+			opts.NormalizeIndent = false
+			if got := Minify(tt.content, comments, opts); got != tt.want {
+				t.Errorf("Minify =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	content := "// Copyright 2024 Example.\n// SPDX-License-Identifier: MIT\n\npackage p\n\n// F does things.\nfunc F() {} // trailing\n\n// stray\n\n//go:generate echo\n"
+	want := []struct {
+		text string
+		kind Kind
+	}{
+		{"// Copyright 2024 Example.", License},
+		{"// SPDX-License-Identifier: MIT", License},
+		{"// F does things.", Doc},
+		{"// trailing", Inline},
+		{"// stray", Inline},
+		{"//go:generate echo", Directive},
+	}
+
+	found := Classify(content, commentsOf(t, "Go"))
+	if len(found) != len(want) {
+		t.Fatalf("found %d comments, want %d", len(found), len(want))
+	}
+	for i, comment := range found {
+		if text := content[comment.Start:comment.End]; text != want[i].text || comment.Kind != want[i].kind {
+			t.Errorf("comment %d = %q, kind %d, want %q, kind %d", i, text, comment.Kind, want[i].text, want[i].kind)
+		}
+	}
+}
+
+func TestDeduplicateHeaders(t *testing.T) {
+	const license = "// Copyright 2024 Example Authors.\n// Licensed under the Apache License, Version 2.0.\n"
+	const doc = "// This package holds the core types shared by every command.\n"
+	tests := []struct {
+		name    string
+		headers []string
+		shared  bool
+	}{
+		{"license in three files", []string{license, license, license}, true},
+		{"license in two files", []string{license, license}, false},
+		{"doc comment in three files", []string{doc, doc, doc}, false},
+		{"different licenses", []string{license, license, "// Copyright 2023 Someone Else.\n// All rights reserved.\n"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []string
+			contents := make(map[string]string)
+			for i, header := range tt.headers {
+				file := string(rune('a'+i)) + ".go"
+				files = append(files, file)
+				contents[file] = header + "\npackage p\n"
+			}
+			headers := DeduplicateHeaders(files, contents, func(string) languages.Comments { return commentsOf(t, "Go") })
+
+			if shared := len(headers) == 1; shared != tt.shared {
+				t.Fatalf("headers = %v, want shared %v", headers, tt.shared)
+			}
+			for _, file := range files {
+				if replaced := strings.HasPrefix(contents[file], "[shared header 1]"); replaced != tt.shared {
+					t.Errorf("%s = %q, want the header replaced: %v", file, contents[file], tt.shared)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,634 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+
+	"codecopy/helpers"
+)
+
+// ErrCancelled is returned when the user leaves the picker without confirming.
+var ErrCancelled = errors.New("selection cancelled")
+
+// newScreen opens the terminal the picker is drawn on.
+var newScreen = tcell.NewScreen
+
+// Mode is how a selected file is included.
+type Mode int
+
+const (
+	// Full includes the whole file.
+	Full Mode = iota
+	// Outline includes only the file's declarations.
+	Outline
+	// Truncated includes the head and tail of the file.
+	Truncated
+)
+
+// String returns the label shown next to files included in the mode.
+func (m Mode) String() string {
+	switch m {
+	case Outline:
+		return "outline"
+	case Truncated:
+		return "truncated"
+	}
+	return "full"
+}
+
+// Choice is a selected file and how it is included.
+type Choice struct {
+	Path string
+	Mode Mode
+}
+
+// Options configures the picker.
+type Options struct {
+	// Title is shown in the header line.
+	Title string
+	// Budget is the token budget the running total is compared against.
+	Budget int
+	// Selected lists the files checked when the picker opens.
+	Selected []string
+	// Modes sets how files are included when the picker opens; files not
+	// listed are included in full.
+	Modes map[string]Mode
+	// Downgrade lets the user switch files to outlines or truncated content.
+	Downgrade bool
+	// Tokens holds token counts already known for files in their initial mode.
+	Tokens map[string]int
+	// Count returns the token count of a file included in the given mode. It
+	// is called in the background, and its errors are shown in place of the
+	// count.
+	Count func(path string, mode Mode) (int, error)
+}
+
+// countRequest asks the background counter for a file's tokens in a mode.
+type countRequest struct {
+	node *node
+	mode Mode
+}
+
+// countResult carries a background token count to the event loop.
+type countResult struct {
+	countRequest
+	tokens int
+	err    error
+}
+
+// picker is the state of a running picker.
+type picker struct {
+	opts     Options
+	rootDir  string
+	root     *node
+	visible  []*node
+	cursor   int
+	offset   int
+	filter   string
+	typing   bool
+	finished bool
+	err      error
+	requests chan countRequest
+
+	// showPreview is nil until the user toggles the preview pane, which is
+	// otherwise shown on wide terminals.
+	showPreview *bool
+	previews    map[string]*preview
+	screen      tcell.Screen
+}
+
+// Run shows a full-screen tree of files under rootDir with checkboxes and
+// live token totals, and returns the checked files in their original order
+// with the mode each is included in.
+func Run(rootDir string, files []string, opts Options) ([]Choice, error) {
+	screen, err := newScreen()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open terminal: %v", err)
+	}
+	if err := screen.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize terminal: %v", err)
+	}
+	defer screen.Fini()
+
+	root, byPath := buildTree(rootDir, files, 1)
+	for _, file := range opts.Selected {
+		if n, ok := byPath[file]; ok {
+			n.checked = true
+		}
+	}
+	for file, mode := range opts.Modes {
+		if n, ok := byPath[file]; ok {
+			n.mode = mode
+		}
+	}
+	for file, tokens := range opts.Tokens {
+		if n, ok := byPath[file]; ok {
+			n.counts[n.mode] = tokens
+		}
+	}
+
+	p := &picker{
+		opts:     opts,
+		rootDir:  rootDir,
+		root:     root,
+		previews: make(map[string]*preview),
+		screen:   screen,
+		// Each file is counted at most once per mode, so requests never block.
+		requests: make(chan countRequest, 3*len(files)),
+	}
+	p.refresh()
+
+	defer close(p.requests)
+	if opts.Count != nil {
+		go countFiles(screen, p.requests, opts.Count)
+		for _, file := range files {
+			p.requestCount(byPath[file])
+		}
+	}
+
+	for !p.finished {
+		p.draw(screen)
+		switch event := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			p.handleKey(event)
+		case *tcell.EventInterrupt:
+			if result, ok := event.Data().(countResult); ok {
+				delete(result.node.pending, result.mode)
+				if result.err != nil {
+					result.node.errs[result.mode] = result.err
+				} else {
+					result.node.counts[result.mode] = result.tokens
+				}
+			}
+			if result, ok := event.Data().(previewResult); ok {
+				p.previews[result.path] = result.preview
+			}
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	var choices []Choice
+	for _, file := range files {
+		if n := byPath[file]; n.checked {
+			choices = append(choices, Choice{Path: file, Mode: n.mode})
+		}
+	}
+	return choices, nil
+}
+
+// countFiles counts tokens for each request and posts the results to the screen.
+func countFiles(screen tcell.Screen, requests <-chan countRequest, count func(string, Mode) (int, error)) {
+	for request := range requests {
+		tokens, err := count(request.node.path, request.mode)
+		if screen.PostEvent(tcell.NewEventInterrupt(countResult{countRequest: request, tokens: tokens, err: err})) != nil {
+			return
+		}
+	}
+}
+
+// requestCount queues a background count of the file in its current mode
+// unless it is known or already queued.
+func (p *picker) requestCount(n *node) {
+	if p.opts.Count == nil || n.pending[n.mode] {
+		return
+	}
+	if _, known, _ := n.count(); known {
+		return
+	}
+	n.pending[n.mode] = true
+	p.requests <- countRequest{node: n, mode: n.mode}
+}
+
+// refresh recomputes the visible rows and keeps the cursor within them.
+func (p *picker) refresh() {
+	p.visible = visibleNodes(p.root, p.filter)
+	p.cursor = max(0, min(p.cursor, len(p.visible)-1))
+}
+
+// current returns the node under the cursor, or nil if nothing is visible.
+func (p *picker) current() *node {
+	if len(p.visible) == 0 {
+		return nil
+	}
+	return p.visible[p.cursor]
+}
+
+// handleKey applies a key press.
+func (p *picker) handleKey(event *tcell.EventKey) {
+	switch event.Key() {
+	case tcell.KeyCtrlC:
+		p.finished, p.err = true, ErrCancelled
+		return
+	case tcell.KeyUp:
+		p.move(-1)
+		return
+	case tcell.KeyDown:
+		p.move(1)
+		return
+	case tcell.KeyPgUp:
+		p.move(-10)
+		return
+	case tcell.KeyPgDn:
+		p.move(10)
+		return
+	case tcell.KeyHome:
+		p.move(-len(p.visible))
+		return
+	case tcell.KeyEnd:
+		p.move(len(p.visible))
+		return
+	}
+
+	if p.typing {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			p.typing, p.filter = false, ""
+		case tcell.KeyEnter:
+			p.typing = false
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if runes := []rune(p.filter); len(runes) > 0 {
+				p.filter = string(runes[:len(runes)-1])
+			}
+		case tcell.KeyRune:
+			p.filter += string(event.Rune())
+		}
+		p.cursor = 0
+		p.refresh()
+		return
+	}
+
+	switch event.Key() {
+	case tcell.KeyEnter:
+		p.finished = true
+	case tcell.KeyEscape:
+		if p.filter != "" {
+			p.filter = ""
+			p.refresh()
+		} else {
+			p.finished, p.err = true, ErrCancelled
+		}
+	case tcell.KeyRight:
+		p.expand(true)
+	case tcell.KeyLeft:
+		p.expand(false)
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case ' ':
+			p.toggle()
+		case 'j':
+			p.move(1)
+		case 'k':
+			p.move(-1)
+		case 'l':
+			p.expand(true)
+		case 'h':
+			p.expand(false)
+		case '/':
+			p.typing = true
+		case 'a':
+			p.toggleNode(p.root)
+		case 'o':
+			p.setMode(Outline)
+		case 't':
+			p.setMode(Truncated)
+		case 'p':
+			width, _ := p.screen.Size()
+			show := !p.previewVisible(width)
+			p.showPreview = &show
+		case 'q':
+			p.finished, p.err = true, ErrCancelled
+		}
+	}
+}
+
+func (p *picker) move(delta int) {
+	p.cursor = max(0, min(p.cursor+delta, len(p.visible)-1))
+}
+
+// expand opens or closes the directory under the cursor. Closing a file or a
+// closed directory moves to its parent.
+func (p *picker) expand(open bool) {
+	n := p.current()
+	if n == nil {
+		return
+	}
+	if n.dir && n.expanded != open && p.filter == "" {
+		n.expanded = open
+		p.refresh()
+		return
+	}
+	if !open && n.parent != nil && n.parent != p.root {
+		for i, v := range p.visible {
+			if v == n.parent {
+				p.cursor = i
+				break
+			}
+		}
+	}
+}
+
+// toggle checks or unchecks the file or directory under the cursor.
+func (p *picker) toggle() {
+	if n := p.current(); n != nil {
+		p.toggleNode(n)
+	}
+}
+
+// toggleNode checks every file under n that matches the filter, or unchecks
+// them if they are all checked already.
+func (p *picker) toggleNode(n *node) {
+	var matching []*node
+	n.files(func(f *node) {
+		if fuzzyMatch(p.filter, f.relPath) {
+			matching = append(matching, f)
+		}
+	})
+
+	check := false
+	for _, f := range matching {
+		if !f.checked {
+			check = true
+			break
+		}
+	}
+	for _, f := range matching {
+		f.checked = check
+	}
+}
+
+// setMode switches the file under the cursor, or the checked files of the
+// directory under the cursor, to mode, or back to full if they are all in
+// that mode already. A file switched on its own is also checked.
+func (p *picker) setMode(mode Mode) {
+	n := p.current()
+	if !p.opts.Downgrade || n == nil {
+		return
+	}
+
+	var targets []*node
+	if n.dir {
+		n.files(func(f *node) {
+			if f.checked && fuzzyMatch(p.filter, f.relPath) {
+				targets = append(targets, f)
+			}
+		})
+	} else {
+		n.checked = true
+		targets = []*node{n}
+	}
+
+	next := Full
+	for _, f := range targets {
+		if f.mode != mode {
+			next = mode
+			break
+		}
+	}
+	for _, f := range targets {
+		f.mode = next
+		p.requestCount(f)
+	}
+}
+
+// selection returns the number of checked files and their known tokens, and
+// whether any checked file is still being counted.
+func (p *picker) selection() (files, tokens int, pending bool) {
+	p.root.files(func(f *node) {
+		if !f.checked {
+			return
+		}
+		files++
+		count, known, _ := f.count()
+		tokens += count
+		if !known {
+			pending = true
+		}
+	})
+	return files, tokens, pending
+}
+
+var (
+	styleDefault  = tcell.StyleDefault
+	styleHeader   = tcell.StyleDefault.Bold(true)
+	styleDim      = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	styleCursor   = tcell.StyleDefault.Reverse(true)
+	styleDir      = tcell.StyleDefault.Foreground(tcell.ColorTeal).Bold(true)
+	styleChecked  = tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	styleOver     = tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+	styleWithin   = tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
+	styleError    = tcell.StyleDefault.Foreground(tcell.ColorRed)
+	checkboxes    = map[checkState]string{checkedNone: "[ ]", checkedSome: "[~]", checkedAll: "[x]"}
+	expandMarkers = map[bool]string{false: "▸ ", true: "▾ "}
+)
+
+// draw renders the header, the visible rows and the key help.
+func (p *picker) draw(screen tcell.Screen) {
+	screen.Clear()
+	width, height := screen.Size()
+
+	files, tokens, pending := p.selection()
+	total := fmt.Sprintf("%d files · %d tokens", files, tokens)
+	if pending {
+		total += " (counting…)"
+	}
+	totalStyle := styleWithin
+	if p.opts.Budget > 0 {
+		total += fmt.Sprintf(" / %d budget", p.opts.Budget)
+		if tokens > p.opts.Budget {
+			totalStyle = styleOver
+		}
+	}
+	x := drawText(screen, 0, 0, width, styleHeader, p.opts.Title+"  ")
+	drawText(screen, x, 0, width, totalStyle, total)
+
+	switch {
+	case p.typing:
+		drawText(screen, 0, 1, width, styleDefault, "Filter: "+p.filter+"█")
+	case p.filter != "":
+		drawText(screen, 0, 1, width, styleDim, "Filter: "+p.filter+"  (Esc to clear)")
+	}
+
+	listTop, listHeight := 2, height-3
+	treeWidth := width
+	if p.previewVisible(width) {
+		treeWidth = width * 11 / 20
+		for y := listTop; y < listTop+listHeight; y++ {
+			screen.SetContent(treeWidth, y, '│', nil, styleDim)
+		}
+		p.drawPreview(screen, treeWidth+2, listTop, width, listHeight)
+	}
+	p.drawTree(screen, listTop, listHeight, treeWidth)
+
+	help := "↑↓ move  ←→ collapse/expand  space toggle  a toggle all  / filter  p preview  enter confirm  q cancel"
+	if p.opts.Downgrade {
+		help = "↑↓ move  space toggle  o outline  t truncate  / filter  p preview  enter confirm  q cancel"
+	}
+	drawText(screen, 0, height-1, width, styleDim, help)
+	screen.Show()
+}
+
+// drawTree renders the visible rows between top and top+height, scrolled so
+// the cursor is visible.
+func (p *picker) drawTree(screen tcell.Screen, top, height, width int) {
+	if height <= 0 {
+		return
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+height {
+		p.offset = p.cursor - height + 1
+	}
+
+	for i := 0; i < height && p.offset+i < len(p.visible); i++ {
+		n := p.visible[p.offset+i]
+		y := top + i
+
+		style := styleDefault
+		if n.dir {
+			style = styleDir
+		} else if n.checked {
+			style = styleChecked
+		}
+
+		count, countStyle := p.countLabel(n), styleDim
+		if _, _, err := n.count(); !n.dir && err != nil {
+			countStyle = styleError
+		}
+
+		line := fmt.Sprintf("%*s%s ", n.depth*2, "", checkboxes[n.state()])
+		if n.dir {
+			line += expandMarkers[n.expanded || p.filter != ""]
+		} else {
+			line += "  "
+		}
+
+		if p.offset+i == p.cursor {
+			style, countStyle = styleCursor, styleCursor
+			for x := 0; x < width; x++ {
+				screen.SetContent(x, y, ' ', nil, styleCursor)
+			}
+		}
+		x := drawText(screen, 0, y, width, style, line+n.name)
+		if !n.dir && n.mode != Full {
+			modeStyle := styleDim
+			if p.offset+i == p.cursor {
+				modeStyle = styleCursor
+			}
+			x = drawText(screen, x, y, width, modeStyle, " ("+n.mode.String()+")")
+		}
+		countX := max(x+1, width-runewidth.StringWidth(count)-1)
+		drawText(screen, countX, y, width, countStyle, count)
+	}
+}
+
+// previewVisible reports whether the preview pane is shown at the given terminal width.
+func (p *picker) previewVisible(width int) bool {
+	if p.showPreview != nil {
+		return *p.showPreview
+	}
+	return width >= previewMinWidth
+}
+
+// drawPreview renders details and the highlighted head of the node under the
+// cursor in the area from (left, top) to maxX, loading file previews in the
+// background.
+func (p *picker) drawPreview(screen tcell.Screen, left, top, maxX, height int) {
+	n := p.current()
+	if n == nil || height <= 0 {
+		return
+	}
+
+	y := top
+	line := func(style tcell.Style, text string) {
+		if y < top+height {
+			drawText(screen, left, y, maxX, style, text)
+			y++
+		}
+	}
+
+	line(styleHeader, n.relPath)
+	if n.dir {
+		files, checked := 0, 0
+		n.files(func(f *node) {
+			files++
+			if f.checked {
+				checked++
+			}
+		})
+		line(styleDefault, fmt.Sprintf("%d files, %d selected · %d tokens", files, checked, n.totalTokens()))
+		return
+	}
+
+	pv, ok := p.previews[n.path]
+	if !ok {
+		p.previews[n.path] = nil
+		go func(path string) {
+			_ = screen.PostEvent(tcell.NewEventInterrupt(previewResult{path: path, preview: loadPreview(p.rootDir, path)}))
+		}(n.path)
+	}
+	if pv == nil {
+		line(styleDim, "loading…")
+		return
+	}
+	if pv.err != nil {
+		line(styleError, pv.err.Error())
+		return
+	}
+
+	line(styleDefault, fmt.Sprintf("%s · %s tokens · modified %s", helpers.FormatSize(pv.size), p.countLabel(n), pv.modTime.Format("2006-01-02 15:04")))
+	if pv.commit != "" {
+		line(styleDim, pv.commit)
+	}
+	line(styleDim, "")
+	if pv.binary {
+		line(styleDim, "binary file")
+		return
+	}
+
+	for _, segments := range pv.lines {
+		if y >= top+height {
+			break
+		}
+		x := left
+		for _, seg := range segments {
+			x = drawText(screen, x, y, maxX, seg.style, seg.text)
+		}
+		y++
+	}
+}
+
+// countLabel returns the token count shown for a node.
+func (p *picker) countLabel(n *node) string {
+	if n.dir {
+		return fmt.Sprintf("%d", n.totalTokens())
+	}
+	tokens, known, err := n.count()
+	switch {
+	case err != nil:
+		return "n/a"
+	case !known:
+		return "…"
+	}
+	return fmt.Sprintf("%d", tokens)
+}
+
+// drawText writes text at (x, y), clipped at maxX, and returns the column after it.
+func drawText(screen tcell.Screen, x, y, maxX int, style tcell.Style, text string) int {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > maxX {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	return x
+}
@@ -0,0 +1,140 @@
+package picker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// scriptedScreen is a simulated terminal that delivers a fixed sequence of
+// key presses once the picker initializes it.
+type scriptedScreen struct {
+	tcell.SimulationScreen
+	keys []*tcell.EventKey
+}
+
+func (s *scriptedScreen) Init() error {
+	if err := s.SimulationScreen.Init(); err != nil {
+		return err
+	}
+	s.SetSize(120, 30)
+	for _, key := range s.keys {
+		if err := s.PostEvent(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func key(k tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(k, 0, tcell.ModNone)
+}
+
+func runeKey(r rune) *tcell.EventKey {
+	return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+}
+
+// runScripted runs the picker on a.go, b.go and c.go, all selected, with the
+// given key presses.
+func runScripted(t *testing.T, opts Options, keys ...*tcell.EventKey) ([]Choice, []string, error) {
+	t.Helper()
+	rootDir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		path := filepath.Join(rootDir, name)
+		if err := os.WriteFile(path, []byte("package p\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	previous := newScreen
+	newScreen = func() (tcell.Screen, error) {
+		return &scriptedScreen{SimulationScreen: tcell.NewSimulationScreen(""), keys: keys}, nil
+	}
+	t.Cleanup(func() { newScreen = previous })
+
+	opts.Selected = files
+	choices, err := Run(rootDir, files, opts)
+	return choices, files, err
+}
+
+func TestRunChoices(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		keys  []*tcell.EventKey
+		modes map[int]Mode
+	}{
+		{
+			name:  "confirm",
+			keys:  []*tcell.EventKey{key(tcell.KeyEnter)},
+			modes: map[int]Mode{0: Full, 1: Full, 2: Full},
+		},
+		{
+			name:  "uncheck",
+			keys:  []*tcell.EventKey{key(tcell.KeyDown), runeKey(' '), key(tcell.KeyEnter)},
+			modes: map[int]Mode{0: Full, 2: Full},
+		},
+		{
+			name:  "downgrade",
+			opts:  Options{Downgrade: true},
+			keys:  []*tcell.EventKey{runeKey('o'), key(tcell.KeyDown), key(tcell.KeyDown), runeKey('t'), key(tcell.KeyEnter)},
+			modes: map[int]Mode{0: Outline, 1: Full, 2: Truncated},
+		},
+		{
+			name:  "downgrade disabled",
+			keys:  []*tcell.EventKey{runeKey('o'), key(tcell.KeyEnter)},
+			modes: map[int]Mode{0: Full, 1: Full, 2: Full},
+		},
+		{
+			name:  "escape clears the filter first",
+			keys:  []*tcell.EventKey{runeKey('/'), runeKey('b'), key(tcell.KeyEnter), key(tcell.KeyEscape), key(tcell.KeyEnter)},
+			modes: map[int]Mode{0: Full, 1: Full, 2: Full},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choices, files, err := runScripted(t, tt.opts, tt.keys...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []Choice
+			for i, file := range files {
+				if mode, ok := tt.modes[i]; ok {
+					want = append(want, Choice{Path: file, Mode: mode})
+				}
+			}
+			if !reflect.DeepEqual(choices, want) {
+				t.Errorf("choices = %v, want %v", choices, want)
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []*tcell.EventKey
+	}{
+		{"escape", []*tcell.EventKey{key(tcell.KeyEscape)}},
+		{"escape after changes", []*tcell.EventKey{runeKey(' '), runeKey('o'), key(tcell.KeyEscape)}},
+		{"q", []*tcell.EventKey{runeKey('q')}},
+		{"ctrl-c", []*tcell.EventKey{key(tcell.KeyCtrlC)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choices, _, err := runScripted(t, Options{Downgrade: true}, tt.keys...)
+			if !errors.Is(err, ErrCancelled) {
+				t.Fatalf("err = %v, want ErrCancelled", err)
+			}
+			if choices != nil {
+				t.Errorf("cancelled picker returned choices %v", choices)
+			}
+		})
+	}
+}
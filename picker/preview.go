@@ -0,0 +1,167 @@
+package picker
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/gdamore/tcell/v2"
+
+	"codecopy/helpers"
+)
+
+const (
+	// previewBytes is the number of leading bytes read for a preview.
+	previewBytes = 16 * 1024
+	// previewMinWidth is the terminal width from which the preview pane is shown by default.
+	previewMinWidth = 100
+	// tabWidth is the number of columns a tab is expanded to in the preview.
+	tabWidth = 4
+)
+
+// preview holds what the preview pane shows for a file.
+type preview struct {
+	size    int64
+	modTime time.Time
+	commit  string
+	binary  bool
+	lines   [][]segment
+	err     error
+}
+
+// segment is a run of text drawn in one style.
+type segment struct {
+	text  string
+	style tcell.Style
+}
+
+// previewResult carries a loaded preview to the event loop.
+type previewResult struct {
+	path    string
+	preview *preview
+}
+
+// loadPreview reads the head of a file, highlights it and looks up its last commit.
+func loadPreview(rootDir, path string) *preview {
+	p := &preview{}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.size, p.modTime = info.Size(), info.ModTime()
+
+	file, err := os.Open(path)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	defer file.Close()
+
+	head, err := io.ReadAll(io.LimitReader(file, previewBytes))
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	p.commit = lastCommit(rootDir, path)
+
+	// A read cut mid-rune at the limit is not a sign of binary content.
+	valid := head
+	for i := 0; i < utf8.UTFMax && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+	if _, reason := helpers.SniffBinary(valid); reason != "" {
+		p.binary = true
+		return p
+	}
+
+	p.lines = highlight(path, string(valid))
+	return p
+}
+
+// lastCommit describes the most recent commit touching path, or returns ""
+// outside a git repository.
+func lastCommit(rootDir, path string) string {
+	cmd := exec.Command("git", "log", "-1", "--format=%h %ar · %an · %s", "--", path)
+	cmd.Dir = rootDir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(output))
+	if commit == "" {
+		return "not committed"
+	}
+	return commit
+}
+
+// highlight splits text into lines of styled segments using the lexer for the file name.
+func highlight(path, text string) [][]segment {
+	text = strings.ReplaceAll(text, "\t", strings.Repeat(" ", tabWidth))
+
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		lexer = lexers.Analyse(text)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, text)
+	if err != nil {
+		return plainLines(text)
+	}
+
+	lines := [][]segment{nil}
+	for _, token := range iterator.Tokens() {
+		style := tokenStyle(token.Type)
+		parts := strings.Split(token.Value, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				last := len(lines) - 1
+				lines[last] = append(lines[last], segment{text: part, style: style})
+			}
+		}
+	}
+	return lines
+}
+
+// plainLines splits text into unstyled lines.
+func plainLines(text string) [][]segment {
+	var lines [][]segment
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, []segment{{text: line, style: styleDefault}})
+	}
+	return lines
+}
+
+// tokenStyle maps a token type to a terminal style.
+func tokenStyle(tokenType chroma.TokenType) tcell.Style {
+	switch {
+	case tokenType.InCategory(chroma.Comment):
+		return styleDim
+	case tokenType.InCategory(chroma.Keyword):
+		return tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
+	case tokenType.InSubCategory(chroma.LiteralString):
+		return tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	case tokenType.InSubCategory(chroma.LiteralNumber):
+		return tcell.StyleDefault.Foreground(tcell.ColorFuchsia)
+	case tokenType == chroma.NameFunction || tokenType == chroma.NameClass || tokenType == chroma.NameBuiltin:
+		return tcell.StyleDefault.Foreground(tcell.ColorAqua)
+	case tokenType.InCategory(chroma.Name) && tokenType != chroma.Name:
+		return tcell.StyleDefault.Foreground(tcell.ColorTeal)
+	}
+	return styleDefault
+}
@@ -0,0 +1,211 @@
+package picker
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// node is a file or directory in the picker tree.
+type node struct {
+	name     string
+	path     string
+	relPath  string
+	dir      bool
+	depth    int
+	parent   *node
+	children []*node
+	expanded bool
+
+	// checked, mode, counts, errs and pending apply to files. The token
+	// count, or the error counting it, is kept for each mode it was counted in.
+	checked bool
+	mode    Mode
+	counts  map[Mode]int
+	errs    map[Mode]error
+	pending map[Mode]bool
+}
+
+// checkState is the selection state of a directory's files.
+type checkState int
+
+const (
+	checkedNone checkState = iota
+	checkedSome
+	checkedAll
+)
+
+// buildTree arranges files under rootDir into a tree with directories first,
+// each level sorted by name. Directories down to expandDepth start expanded.
+func buildTree(rootDir string, files []string, expandDepth int) (*node, map[string]*node) {
+	root := &node{name: filepath.Base(rootDir), path: rootDir, dir: true, expanded: true, depth: -1}
+	byPath := make(map[string]*node)
+	dirs := map[string]*node{"": root}
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(rootDir, file)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			relPath = file
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		parent := root
+		parts := strings.Split(relPath, "/")
+		for i, part := range parts[:len(parts)-1] {
+			dirPath := strings.Join(parts[:i+1], "/")
+			dir, ok := dirs[dirPath]
+			if !ok {
+				dir = &node{
+					name:     part,
+					path:     filepath.Join(rootDir, filepath.FromSlash(dirPath)),
+					relPath:  dirPath,
+					dir:      true,
+					depth:    i,
+					parent:   parent,
+					expanded: i < expandDepth,
+				}
+				dirs[dirPath] = dir
+				parent.children = append(parent.children, dir)
+			}
+			parent = dir
+		}
+
+		leaf := &node{
+			name:    parts[len(parts)-1],
+			path:    file,
+			relPath: relPath,
+			depth:   len(parts) - 1,
+			parent:  parent,
+			counts:  make(map[Mode]int),
+			errs:    make(map[Mode]error),
+			pending: make(map[Mode]bool),
+		}
+		parent.children = append(parent.children, leaf)
+		byPath[file] = leaf
+	}
+
+	sortTree(root)
+	return root, byPath
+}
+
+func sortTree(n *node) {
+	sort.SliceStable(n.children, func(i, j int) bool {
+		a, b := n.children[i], n.children[j]
+		if a.dir != b.dir {
+			return a.dir
+		}
+		return a.name < b.name
+	})
+	for _, child := range n.children {
+		if child.dir {
+			sortTree(child)
+		}
+	}
+}
+
+// files calls fn for every file under n.
+func (n *node) files(fn func(*node)) {
+	if !n.dir {
+		fn(n)
+		return
+	}
+	for _, child := range n.children {
+		child.files(fn)
+	}
+}
+
+// state reports how many of the directory's files are checked.
+func (n *node) state() checkState {
+	if !n.dir {
+		if n.checked {
+			return checkedAll
+		}
+		return checkedNone
+	}
+	total, checked := 0, 0
+	n.files(func(f *node) {
+		total++
+		if f.checked {
+			checked++
+		}
+	})
+	switch {
+	case checked == 0:
+		return checkedNone
+	case checked == total:
+		return checkedAll
+	}
+	return checkedSome
+}
+
+// count returns the file's token count in its current mode. known is false
+// while the count is not yet available.
+func (n *node) count() (tokens int, known bool, err error) {
+	if err, ok := n.errs[n.mode]; ok {
+		return 0, true, err
+	}
+	tokens, known = n.counts[n.mode]
+	return tokens, known, nil
+}
+
+// totalTokens sums the known token counts of all files under n.
+func (n *node) totalTokens() int {
+	total := 0
+	n.files(func(f *node) {
+		tokens, _, _ := f.count()
+		total += tokens
+	})
+	return total
+}
+
+// fuzzyMatch reports whether every character of pattern, ignoring spaces,
+// appears in text in order, ignoring case.
+func fuzzyMatch(pattern, text string) bool {
+	patternRunes := []rune(strings.ToLower(strings.Join(strings.Fields(pattern), "")))
+	if len(patternRunes) == 0 {
+		return true
+	}
+	i := 0
+	for _, r := range strings.ToLower(text) {
+		if r == patternRunes[i] {
+			i++
+			if i == len(patternRunes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibleNodes flattens the expanded part of the tree. With a filter, only
+// files whose path fuzzy-matches it are shown, under their directories.
+func visibleNodes(root *node, filter string) []*node {
+	var visible []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, child := range n.children {
+			if filter != "" && !matchesFilter(child, filter) {
+				continue
+			}
+			visible = append(visible, child)
+			if child.dir && (child.expanded || filter != "") {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return visible
+}
+
+// matchesFilter reports whether n is a matching file or a directory holding one.
+func matchesFilter(n *node, filter string) bool {
+	if !n.dir {
+		return fuzzyMatch(filter, n.relPath)
+	}
+	for _, child := range n.children {
+		if matchesFilter(child, filter) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Rule is a user-defined redaction rule. If the pattern has a capture group
+// named "secret", only that group is redacted; otherwise the whole match is.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Finding records one redacted secret.
+type Finding struct {
+	Rule string
+	Line int
+}
+
+// detector finds one kind of secret.
+type detector struct {
+	name string
+	re   *regexp.Regexp
+	// group is the index of the capture group holding the secret; 0 redacts the whole match.
+	group int
+	// valid, if set, filters out matches that are not actually secrets. It
+	// receives the whole match and the secret within it.
+	valid func(match, secret string) bool
+}
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above which
+// a value assigned to a secret-looking name is treated as a real secret.
+const minSecretEntropy = 3.0
+
+var builtinDetectors = []detector{
+	{
+		name: "private-key",
+		re:   regexp.MustCompile(`(?s)-----BEGIN[A-Z ]*PRIVATE KEY( BLOCK)?-----.*?-----END[A-Z ]*PRIVATE KEY( BLOCK)?-----`),
+	},
+	{
+		name: "aws-access-key-id",
+		re:   regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	{
+		name: "gcp-api-key",
+		re:   regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`),
+	},
+	{
+		name: "github-token",
+		re:   regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	},
+	{
+		name: "slack-token",
+		re:   regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+	},
+	{
+		name: "slack-webhook",
+		re:   regexp.MustCompile(`https://hooks\.slack\.com/services/[A-Za-z0-9_/]+`),
+	},
+	{
+		name: "jwt",
+		re:   regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+	},
+	{
+		name:  "connection-string-password",
+		re:    regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^:/\s@"']+:([^@\s/"']+)@`),
+		group: 1,
+		valid: func(match, secret string) bool { return !isPlaceholder(secret) },
+	},
+	{
+		name:  "secret-assignment",
+		re:    regexp.MustCompile(`(?i)[a-z0-9_.-]*(?:secret|token|passwd|password|pwd|api[_-]?key|access[_-]?key|private[_-]?key|credential)[a-z0-9_.-]*["']?\s*(?::=|=|:)\s*["']?([A-Za-z0-9+/=_\-!@#$%^&*.~]{8,})`),
+		group: 1,
+		valid: isLikelySecretAssignment,
+	},
+}
+
+// Redactor replaces secrets in file content with labeled placeholders.
+type Redactor struct {
+	detectors []detector
+}
+
+// New creates a redactor with the built-in detectors followed by the given user rules.
+func New(rules []Rule) (*Redactor, error) {
+	r := &Redactor{detectors: append([]detector(nil), builtinDetectors...)}
+
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %v", rule.Name, err)
+		}
+		name := rule.Name
+		if name == "" {
+			name = "custom"
+		}
+		r.detectors = append(r.detectors, detector{name: name, re: re, group: re.SubexpIndex("secret")})
+	}
+
+	return r, nil
+}
+
+// span is a detected secret's byte range within the original content.
+type span struct {
+	start, end int
+	rule       string
+}
+
+// Redact replaces every detected secret in content with "[REDACTED:<rule>]" and
+// reports what was replaced, ordered by position. Where detections overlap,
+// the earliest one wins.
+func (r *Redactor) Redact(content string) (string, []Finding) {
+	var spans []span
+	for _, d := range r.detectors {
+		for _, match := range d.re.FindAllStringSubmatchIndex(content, -1) {
+			start, end := match[0], match[1]
+			if d.group > 0 {
+				start, end = match[2*d.group], match[2*d.group+1]
+			}
+			if start < 0 || start == end {
+				continue
+			}
+			if d.valid != nil && !d.valid(content[match[0]:match[1]], content[start:end]) {
+				continue
+			}
+			spans = append(spans, span{start: start, end: end, rule: d.name})
+		}
+	}
+	if len(spans) == 0 {
+		return content, nil
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var redacted strings.Builder
+	var findings []Finding
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		findings = append(findings, Finding{Rule: s.rule, Line: strings.Count(content[:s.start], "\n") + 1})
+		redacted.WriteString(content[last:s.start])
+		redacted.WriteString("[REDACTED:" + s.rule + "]")
+		last = s.end
+	}
+	redacted.WriteString(content[last:])
+
+	return redacted.String(), findings
+}
+
+// nonSecretNamePattern matches names that describe something about a secret
+// rather than hold it, such as "token_id", "passwordHash" or "secret-name".
+var nonSecretNamePattern = regexp.MustCompile(`[_.-](?i:ids?|hash|digest|checksum|type|name|count|length|ur[il]|path|file)$|[a-z0-9](?:Ids?|IDs?|Hash|Digest|Checksum|Type|Name|Count|Length|Ur[il]|UR[IL]|Path|File)$`)
+
+// isLikelySecretAssignment reports whether an assignment to a secret-looking
+// name holds a real credential: the name must not merely describe the secret,
+// and the value must look like one.
+func isLikelySecretAssignment(match, value string) bool {
+	name := match
+	if end := strings.IndexAny(match, "\"' \t:="); end >= 0 {
+		name = match[:end]
+	}
+	return !nonSecretNamePattern.MatchString(name) && isLikelySecretValue(value)
+}
+
+// identifierPathPattern matches code references such as "os.Getenv" or "config.DBPassword".
+var identifierPathPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// isLikelySecretValue reports whether a value assigned to a secret-looking name
+// looks like a real credential rather than code, a placeholder or a word.
+func isLikelySecretValue(value string) bool {
+	if isPlaceholder(value) || entropy(value) < minSecretEntropy {
+		return false
+	}
+	if strings.Contains(value, ".") && identifierPathPattern.MatchString(value) {
+		return false
+	}
+	return strings.ContainsAny(value, "0123456789") && strings.IndexFunc(value, unicode.IsLetter) >= 0
+}
+
+// isPlaceholder reports whether a value is an obvious stand-in rather than a real secret.
+func isPlaceholder(value string) bool {
+	lower := strings.ToLower(value)
+	for _, marker := range []string{"${", "{{", "<", "xxx", "changeme", "example", "placeholder", "your", "redacted", "password", "secret"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return strings.Trim(value, "*.") == ""
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var bits float64
+	n := float64(len([]rune(s)))
+	for _, count := range counts {
+		p := float64(count) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
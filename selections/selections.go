@@ -0,0 +1,118 @@
+package selections
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// File is a selected file, relative to the repository root with forward
+// slashes, and how it is included.
+type File struct {
+	Path string `json:"path"`
+	// Mode is "outline" or "truncated" for downgraded files, and empty for
+	// files included in full.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Selection is the files a run copied together with the flags that shaped
+// their content, such as --minify or --max-lines.
+type Selection struct {
+	Files []File    `json:"files"`
+	Args  []string  `json:"args,omitempty"`
+	Saved time.Time `json:"saved"`
+}
+
+// Existing returns the files of the selection that still exist under rootDir
+// and the paths of those that no longer do.
+func (s *Selection) Existing(rootDir string) ([]File, []string) {
+	var existing []File
+	var missing []string
+	for _, file := range s.Files {
+		info, err := os.Stat(filepath.Join(rootDir, filepath.FromSlash(file.Path)))
+		if err != nil || info.IsDir() {
+			missing = append(missing, file.Path)
+			continue
+		}
+		existing = append(existing, file)
+	}
+	return existing, missing
+}
+
+// Store holds the selections saved for one repository: the last run's and
+// any named sets.
+type Store struct {
+	Root string                `json:"root"`
+	Last *Selection            `json:"last,omitempty"`
+	Sets map[string]*Selection `json:"sets,omitempty"`
+
+	path string
+}
+
+// Open reads the selections saved for the repository at rootDir from the
+// user configuration directory. A repository without saved selections gets
+// an empty store.
+func Open(rootDir string) (*Store, error) {
+	userDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate user config directory: %v", err)
+	}
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(root))
+	name := fmt.Sprintf("%s-%s.json", filepath.Base(root), hex.EncodeToString(sum[:])[:12])
+	store := &Store{
+		Root: root,
+		Sets: make(map[string]*Selection),
+		path: filepath.Join(userDir, "codecopy", "selections", name),
+	}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved selections: %v", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse saved selections %s: %v", store.path, err)
+	}
+	if store.Sets == nil {
+		store.Sets = make(map[string]*Selection)
+	}
+	return store, nil
+}
+
+// Save writes the store back to the user configuration directory.
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save selections: %v", err)
+	}
+	if err := os.WriteFile(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save selections: %v", err)
+	}
+	return nil
+}
+
+// Names returns the names of the saved sets in sorted order.
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.Sets))
+	for name := range s.Sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,50 @@
+package summarize
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sampleRows is the number of data rows shown in a CSV summary.
+const sampleRows = 5
+
+// summarizeCSV summarizes delimited data as its header, the first rows and the row count.
+func summarizeCSV(content string, comma rune) (string, string, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var shown [][]string
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse CSV: %v", err)
+		}
+		if rows <= sampleRows {
+			shown = append(shown, record)
+		}
+		rows++
+	}
+	if rows == 0 {
+		return "", "", errors.New("empty file")
+	}
+
+	var b strings.Builder
+	writer := csv.NewWriter(&b)
+	writer.Comma = comma
+	if err := writer.WriteAll(shown); err != nil {
+		return "", "", fmt.Errorf("failed to write CSV sample: %v", err)
+	}
+
+	dataRows := rows - 1
+	description := fmt.Sprintf("header and %d of %d rows", len(shown)-1, dataRows)
+	return b.String(), description, nil
+}
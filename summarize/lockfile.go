@@ -0,0 +1,448 @@
+package summarize
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dependency is a package pinned by a lockfile.
+type dependency struct {
+	Name    string
+	Version string
+}
+
+// lockfileParsers maps lockfile names to a parser returning the direct
+// dependencies, or every locked package when the lockfile does not say which
+// are direct, and the total number of locked packages.
+var lockfileParsers = map[string]func(path, content string) (deps []dependency, direct bool, total int, err error){
+	"package-lock.json":   parseNPMLock,
+	"npm-shrinkwrap.json": parseNPMLock,
+	"yarn.lock":           parseYarnLock,
+	"pnpm-lock.yaml":      parsePNPMLock,
+	"Cargo.lock":          parseCargoLock,
+	"poetry.lock":         parseCargoLock,
+	"go.sum":              parseGoSum,
+	"Gemfile.lock":        parseGemfileLock,
+	"composer.lock":       parseComposerLock,
+	"Pipfile.lock":        parsePipfileLock,
+	"pubspec.lock":        parsePubspecLock,
+}
+
+// isLockfile reports whether the path is a lockfile that can be summarized.
+func isLockfile(path string) bool {
+	_, ok := lockfileParsers[filepath.Base(path)]
+	return ok
+}
+
+// summarizeLockfile lists the dependencies pinned by a lockfile.
+func summarizeLockfile(path, content string) (string, string, error) {
+	deps, direct, total, err := lockfileParsers[filepath.Base(path)](path, content)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %s: %v", filepath.Base(path), err)
+	}
+	if len(deps) == 0 {
+		return "", "", fmt.Errorf("no dependencies found in %s", filepath.Base(path))
+	}
+
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+
+	var b strings.Builder
+	for _, dep := range deps {
+		b.WriteString(strings.TrimSpace(dep.Name + " " + dep.Version))
+		b.WriteString("\n")
+	}
+
+	description := fmt.Sprintf("lockfile, %d locked packages", total)
+	if direct {
+		description = fmt.Sprintf("lockfile, %d direct dependencies of %d locked packages", len(deps), total)
+	}
+	return b.String(), description, nil
+}
+
+// parseNPMLock reads package-lock.json. Version 2 and later record the root
+// package's dependencies; version 1 lockfiles rely on the sibling package.json.
+func parseNPMLock(path, content string) ([]dependency, bool, int, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Version              string            `json:"version"`
+			Dependencies         map[string]string `json:"dependencies"`
+			DevDependencies      map[string]string `json:"devDependencies"`
+			OptionalDependencies map[string]string `json:"optionalDependencies"`
+		} `json:"packages"`
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal([]byte(content), &lock); err != nil {
+		return nil, false, 0, err
+	}
+
+	if root, ok := lock.Packages[""]; ok {
+		var deps []dependency
+		for _, names := range []map[string]string{root.Dependencies, root.DevDependencies, root.OptionalDependencies} {
+			for name := range names {
+				deps = append(deps, dependency{Name: name, Version: lock.Packages["node_modules/"+name].Version})
+			}
+		}
+		return deps, true, len(lock.Packages) - 1, nil
+	}
+
+	versions := make(map[string]string)
+	for name, dep := range lock.Dependencies {
+		versions[name] = dep.Version
+	}
+	deps, direct := directNPMDependencies(path, versions)
+	return deps, direct, len(lock.Dependencies), nil
+}
+
+// yarnEntryPattern matches the start of a yarn.lock entry, such as
+// `"lodash@^4.17.0", lodash@^4.17.21:`.
+var yarnEntryPattern = regexp.MustCompile(`^"?(@?[^@\s"]+)@`)
+
+// parseYarnLock reads yarn.lock, taking direct dependencies from the sibling package.json.
+func parseYarnLock(path, content string) ([]dependency, bool, int, error) {
+	versions := make(map[string]string)
+	entries := 0
+	current := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			current = ""
+			if match := yarnEntryPattern.FindStringSubmatch(line); match != nil {
+				current = match[1]
+				entries++
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if current != "" && strings.HasPrefix(trimmed, "version") {
+			version := strings.Trim(strings.TrimSpace(strings.TrimLeft(strings.TrimPrefix(trimmed, "version"), ":")), `"`)
+			if _, ok := versions[current]; !ok {
+				versions[current] = version
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, 0, err
+	}
+
+	deps, direct := directNPMDependencies(path, versions)
+	return deps, direct, entries, nil
+}
+
+// directNPMDependencies returns the dependencies declared in the package.json
+// next to a lockfile, with their locked versions. Without a package.json all
+// locked packages are returned and direct is false.
+func directNPMDependencies(lockPath string, versions map[string]string) ([]dependency, bool) {
+	var manifest struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(lockPath), "package.json"))
+	if err != nil || json.Unmarshal(data, &manifest) != nil {
+		return dependencyList(versions), false
+	}
+
+	var deps []dependency
+	for _, names := range []map[string]string{manifest.Dependencies, manifest.DevDependencies, manifest.OptionalDependencies} {
+		for name, specifier := range names {
+			version, ok := versions[name]
+			if !ok {
+				version = specifier
+			}
+			deps = append(deps, dependency{Name: name, Version: version})
+		}
+	}
+	return deps, true
+}
+
+// parsePNPMLock reads pnpm-lock.yaml, whose root importer lists the direct dependencies.
+func parsePNPMLock(_, content string) ([]dependency, bool, int, error) {
+	var lock struct {
+		Importers    map[string]map[string]map[string]any `yaml:"importers"`
+		Dependencies map[string]any                       `yaml:"dependencies"`
+		Packages     map[string]any                       `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &lock); err != nil {
+		return nil, false, 0, err
+	}
+
+	var deps []dependency
+	add := func(name string, value any) {
+		version := fmt.Sprint(value)
+		if spec, ok := value.(map[string]any); ok {
+			version = fmt.Sprint(spec["version"])
+		}
+		if cut, _, found := strings.Cut(version, "("); found {
+			version = cut
+		}
+		deps = append(deps, dependency{Name: name, Version: version})
+	}
+
+	if root, ok := lock.Importers["."]; ok {
+		for _, section := range []string{"dependencies", "devDependencies", "optionalDependencies"} {
+			for name, value := range root[section] {
+				add(name, value)
+			}
+		}
+	} else {
+		for name, value := range lock.Dependencies {
+			add(name, value)
+		}
+	}
+	return deps, true, len(lock.Packages), nil
+}
+
+// parseCargoLock reads the [[package]] tables of Cargo.lock or poetry.lock.
+// In Cargo.lock, workspace packages have no source, and their dependencies
+// are the direct dependencies.
+func parseCargoLock(_, content string) ([]dependency, bool, int, error) {
+	type lockedPackage struct {
+		name, version string
+		hasSource     bool
+		dependencies  []string
+	}
+
+	var packages []*lockedPackage
+	var current *lockedPackage
+	inDependencies := false
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "[[package]]":
+			current = &lockedPackage{}
+			packages = append(packages, current)
+			inDependencies = false
+		case strings.HasPrefix(line, "["):
+			current = nil
+			inDependencies = false
+		case current == nil:
+		case inDependencies:
+			if line == "]" {
+				inDependencies = false
+				continue
+			}
+			current.dependencies = append(current.dependencies, strings.Trim(line, `",`))
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			switch key {
+			case "name":
+				current.name = strings.Trim(value, `"`)
+			case "version":
+				current.version = strings.Trim(value, `"`)
+			case "source":
+				current.hasSource = true
+			case "dependencies":
+				if value == "[" {
+					inDependencies = true
+				} else {
+					for _, dep := range strings.Split(strings.Trim(value, "[]"), ",") {
+						if dep = strings.Trim(strings.TrimSpace(dep), `"`); dep != "" {
+							current.dependencies = append(current.dependencies, dep)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	versions := make(map[string]string)
+	workspace := make(map[string]bool)
+	for _, pkg := range packages {
+		versions[pkg.name] = pkg.version
+		if !pkg.hasSource {
+			workspace[pkg.name] = true
+		}
+	}
+
+	// poetry.lock has no source entries for registry packages, so every
+	// package would look like a workspace member; list them all instead.
+	if len(workspace) == len(packages) {
+		return dependencyList(versions), false, len(packages), nil
+	}
+
+	seen := make(map[string]bool)
+	var deps []dependency
+	for _, pkg := range packages {
+		if pkg.hasSource {
+			continue
+		}
+		for _, dep := range pkg.dependencies {
+			name, version, _ := strings.Cut(dep, " ")
+			if workspace[name] || seen[name] {
+				continue
+			}
+			seen[name] = true
+			if version == "" {
+				version = versions[name]
+			}
+			deps = append(deps, dependency{Name: name, Version: version})
+		}
+	}
+	return deps, true, len(packages), nil
+}
+
+// parseGoSum reads go.sum, taking direct dependencies from the sibling go.mod.
+func parseGoSum(path, content string) ([]dependency, bool, int, error) {
+	modules := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		modules[fields[0]] = strings.TrimSuffix(fields[1], "/go.mod")
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "go.mod"))
+	if err != nil {
+		return dependencyList(modules), false, len(modules), nil
+	}
+
+	var deps []dependency
+	inRequire := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+		case !inRequire:
+			continue
+		}
+		if strings.Contains(line, "// indirect") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			deps = append(deps, dependency{Name: fields[0], Version: fields[1]})
+		}
+	}
+	return deps, true, len(modules), nil
+}
+
+// gemSpecPattern matches a locked gem in the specs of Gemfile.lock, such as "    rails (7.1.3)".
+var gemSpecPattern = regexp.MustCompile(`^    (\S+) \(([^)]+)\)$`)
+
+// parseGemfileLock reads Gemfile.lock, whose DEPENDENCIES section lists the direct dependencies.
+func parseGemfileLock(_, content string) ([]dependency, bool, int, error) {
+	versions := make(map[string]string)
+	var direct []string
+	section := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		if line != "" && !strings.HasPrefix(line, " ") {
+			section = strings.TrimSpace(line)
+			continue
+		}
+		if match := gemSpecPattern.FindStringSubmatch(line); match != nil {
+			versions[match[1]] = match[2]
+		}
+		if section == "DEPENDENCIES" && strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") {
+			name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+			direct = append(direct, strings.TrimSuffix(name, "!"))
+		}
+	}
+
+	var deps []dependency
+	for _, name := range direct {
+		deps = append(deps, dependency{Name: name, Version: versions[name]})
+	}
+	return deps, true, len(versions), nil
+}
+
+// parseComposerLock reads composer.lock, which lists every locked package.
+func parseComposerLock(_, content string) ([]dependency, bool, int, error) {
+	type lockedPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+	if err := json.Unmarshal([]byte(content), &lock); err != nil {
+		return nil, false, 0, err
+	}
+
+	var deps []dependency
+	for _, pkg := range append(lock.Packages, lock.PackagesDev...) {
+		deps = append(deps, dependency{Name: pkg.Name, Version: pkg.Version})
+	}
+	return deps, false, len(deps), nil
+}
+
+// parsePipfileLock reads Pipfile.lock, which lists every locked package.
+func parsePipfileLock(_, content string) ([]dependency, bool, int, error) {
+	type lockedPackage struct {
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Default map[string]lockedPackage `json:"default"`
+		Develop map[string]lockedPackage `json:"develop"`
+	}
+	if err := json.Unmarshal([]byte(content), &lock); err != nil {
+		return nil, false, 0, err
+	}
+
+	versions := make(map[string]string)
+	for _, section := range []map[string]lockedPackage{lock.Default, lock.Develop} {
+		for name, pkg := range section {
+			versions[name] = strings.TrimPrefix(pkg.Version, "==")
+		}
+	}
+	return dependencyList(versions), false, len(versions), nil
+}
+
+// parsePubspecLock reads pubspec.lock, which marks direct dependencies.
+func parsePubspecLock(_, content string) ([]dependency, bool, int, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Dependency string `yaml:"dependency"`
+			Version    string `yaml:"version"`
+		} `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &lock); err != nil {
+		return nil, false, 0, err
+	}
+
+	var deps []dependency
+	for name, pkg := range lock.Packages {
+		if strings.HasPrefix(pkg.Dependency, "direct") {
+			deps = append(deps, dependency{Name: name, Version: pkg.Version})
+		}
+	}
+	return deps, true, len(lock.Packages), nil
+}
+
+// dependencyList converts a map of names to versions into dependencies.
+func dependencyList(versions map[string]string) []dependency {
+	deps := make([]dependency, 0, len(versions))
+	for name, version := range versions {
+		deps = append(deps, dependency{Name: name, Version: version})
+	}
+	return deps
+}
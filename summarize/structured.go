@@ -0,0 +1,370 @@
+package summarize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// object is a decoded JSON or YAML mapping that keeps its key order.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) set(key string, value any) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// summarizeJSON summarizes a JSON document, or a stream of JSON values such
+// as JSON Lines, which is treated as an array of records.
+func summarizeJSON(content string) (string, string, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+
+	var values []any
+	for {
+		value, err := decodeJSON(dec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse JSON: %v", err)
+		}
+		values = append(values, value)
+	}
+
+	return summarizeDocuments(values, "JSON", func(samples []any) string {
+		var b strings.Builder
+		for _, sample := range samples {
+			b.WriteString(truncateSample(marshalJSON(sample)))
+			b.WriteString("\n")
+		}
+		return b.String()
+	})
+}
+
+// summarizeYAML summarizes a YAML file; multiple documents are treated as an
+// array of records.
+func summarizeYAML(content string) (string, string, error) {
+	dec := yaml.NewDecoder(strings.NewReader(content))
+
+	var values []any
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse YAML: %v", err)
+		}
+		values = append(values, fromYAML(&node))
+	}
+
+	return summarizeDocuments(values, "YAML", func(samples []any) string {
+		data, err := yaml.Marshal(toYAML(samples))
+		if err != nil {
+			return ""
+		}
+		return truncateSample(string(data))
+	})
+}
+
+// summarizeDocuments renders the schema of the decoded documents and samples
+// of their largest array.
+func summarizeDocuments(values []any, format string, marshal func(samples []any) string) (string, string, error) {
+	if len(values) == 0 {
+		return "", "", errors.New("empty document")
+	}
+	var document any = values
+	if len(values) == 1 {
+		document = values[0]
+	}
+
+	root := &schema{}
+	root.add(document)
+
+	var b strings.Builder
+	b.WriteString("Schema:\n")
+	b.WriteString(root.render(""))
+	b.WriteString("\n")
+
+	description := format + " schema"
+	if items, path := largestArray(document, "$"); len(items) > 0 {
+		shown := min(sampleCount, len(items))
+		description = fmt.Sprintf("%s and %d of %d records at %s", description, shown, len(items), path)
+		b.WriteString(fmt.Sprintf("\nSample records (%s):\n", path))
+		b.WriteString(marshal(items[:shown]))
+	}
+
+	return b.String(), description, nil
+}
+
+// decodeJSON decodes the next JSON value, keeping object key order.
+func decodeJSON(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := &object{values: make(map[string]any)}
+		for dec.More() {
+			keyToken, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyToken.(string)
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key, value)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case '[':
+		items := []any{}
+		for dec.More() {
+			item, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := dec.Token()
+		return items, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// fromYAML converts a YAML node into the same values decodeJSON produces.
+func fromYAML(node *yaml.Node) any {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return fromYAML(node.Content[0])
+	case yaml.AliasNode:
+		return fromYAML(node.Alias)
+	case yaml.MappingNode:
+		obj := &object{values: make(map[string]any)}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			obj.set(node.Content[i].Value, fromYAML(node.Content[i+1]))
+		}
+		return obj
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			items = append(items, fromYAML(item))
+		}
+		return items
+	}
+
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		return json.Number(node.Value)
+	case "!!bool":
+		return node.Value == "true" || node.Value == "True" || node.Value == "TRUE"
+	case "!!null":
+		return nil
+	}
+	return node.Value
+}
+
+// schema is the inferred shape of a set of values.
+type schema struct {
+	// kinds are the value types seen, in order of first appearance.
+	kinds []string
+	// fields, children and seen describe object values: field names in
+	// order, their schemas and the number of objects each appeared in.
+	fields   []string
+	children map[string]*schema
+	seen     map[string]int
+	objects  int
+	// items is the schema of array elements.
+	items *schema
+}
+
+// add merges a value into the schema.
+func (s *schema) add(value any) {
+	switch value := value.(type) {
+	case *object:
+		s.addKind("object")
+		s.objects++
+		if s.children == nil {
+			s.children = make(map[string]*schema)
+			s.seen = make(map[string]int)
+		}
+		for _, key := range value.keys {
+			child, ok := s.children[key]
+			if !ok {
+				child = &schema{}
+				s.children[key] = child
+				s.fields = append(s.fields, key)
+			}
+			child.add(value.values[key])
+			s.seen[key]++
+		}
+	case []any:
+		s.addKind("array")
+		if s.items == nil {
+			s.items = &schema{}
+		}
+		for _, item := range value {
+			s.items.add(item)
+		}
+	case string:
+		s.addKind("string")
+	case json.Number:
+		s.addKind("number")
+	case bool:
+		s.addKind("boolean")
+	case nil:
+		s.addKind("null")
+	}
+}
+
+func (s *schema) addKind(kind string) {
+	for _, existing := range s.kinds {
+		if existing == kind {
+			return
+		}
+	}
+	s.kinds = append(s.kinds, kind)
+}
+
+// render formats the schema as a type expression; objects span several
+// lines indented from indent.
+func (s *schema) render(indent string) string {
+	var parts []string
+	for _, kind := range s.kinds {
+		switch kind {
+		case "object":
+			parts = append(parts, s.renderObject(indent))
+		case "array":
+			if s.items == nil || len(s.items.kinds) == 0 {
+				parts = append(parts, "[]")
+			} else {
+				parts = append(parts, "["+s.items.render(indent)+"]")
+			}
+		default:
+			parts = append(parts, kind)
+		}
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " | ")
+}
+
+// renderObject formats the fields of an object schema. Fields missing from
+// some of the objects are marked optional with "?".
+func (s *schema) renderObject(indent string) string {
+	if len(s.fields) == 0 {
+		return "{}"
+	}
+
+	var b strings.Builder
+	inner := indent + "  "
+	b.WriteString("{\n")
+	for i, name := range s.fields {
+		if i == maxSchemaFields {
+			b.WriteString(fmt.Sprintf("%s… %d more fields\n", inner, len(s.fields)-i))
+			break
+		}
+		optional := ""
+		if s.seen[name] < s.objects {
+			optional = "?"
+		}
+		b.WriteString(fmt.Sprintf("%s%q%s: %s\n", inner, name, optional, s.children[name].render(inner)))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// largestArray returns the array with the most elements within value, and its path.
+func largestArray(value any, path string) ([]any, string) {
+	var best []any
+	bestPath := ""
+	consider := func(items []any, at string) {
+		if len(items) > len(best) {
+			best, bestPath = items, at
+		}
+	}
+
+	switch value := value.(type) {
+	case []any:
+		consider(value, path)
+		for _, item := range value {
+			consider(largestArray(item, path+"[]"))
+		}
+	case *object:
+		for _, key := range value.keys {
+			consider(largestArray(value.values[key], path+"."+key))
+		}
+	}
+	return best, bestPath
+}
+
+// marshalJSON encodes a value as compact JSON, keeping object key order.
+func marshalJSON(value any) string {
+	switch value := value.(type) {
+	case *object:
+		parts := make([]string, 0, len(value.keys))
+		for _, key := range value.keys {
+			name, _ := json.Marshal(key)
+			parts = append(parts, string(name)+":"+marshalJSON(value.values[key]))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case []any:
+		parts := make([]string, 0, len(value))
+		for _, item := range value {
+			parts = append(parts, marshalJSON(item))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// toYAML converts a decoded value back into a YAML node.
+func toYAML(value any) *yaml.Node {
+	switch value := value.(type) {
+	case *object:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range value.keys {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, toYAML(value.values[key]))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range value {
+			node.Content = append(node.Content, toYAML(item))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(value)}
+}
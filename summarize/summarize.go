@@ -0,0 +1,77 @@
+package summarize
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// sampleCount is the number of sample records or rows shown in a summary.
+	sampleCount = 3
+	// maxSampleBytes caps the length of a single sample record, or of all YAML samples.
+	maxSampleBytes = 1000
+	// maxSchemaFields caps the number of fields listed for one object.
+	maxSchemaFields = 40
+)
+
+// Summarize replaces a large structured data file with a compact description:
+// an inferred schema plus sample records for JSON and YAML, the header plus
+// sample rows for CSV, or the direct dependencies for lockfiles. ok is false
+// if the file is not a supported format or cannot be parsed, in which case
+// the content should be used as is.
+func Summarize(path, content string) (summary string, ok bool) {
+	var body, description string
+	var err error
+
+	switch {
+	case isLockfile(path):
+		body, description, err = summarizeLockfile(path, content)
+	default:
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json", ".jsonl", ".ndjson", ".geojson":
+			body, description, err = summarizeJSON(content)
+		case ".yaml", ".yml":
+			body, description, err = summarizeYAML(content)
+		case ".csv":
+			body, description, err = summarizeCSV(content, ',')
+		case ".tsv":
+			body, description, err = summarizeCSV(content, '\t')
+		default:
+			return "", false
+		}
+	}
+	if err != nil || body == "" {
+		return "", false
+	}
+
+	lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+	return fmt.Sprintf("[summarized: %d lines; %s]\n%s", lines, description, body), true
+}
+
+// Supports reports whether Summarize handles the file's format, judging by
+// its name alone.
+func Supports(path string) bool {
+	if isLockfile(path) {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson", ".geojson", ".yaml", ".yml", ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// truncateSample shortens a sample record that exceeds maxSampleBytes,
+// without splitting a character.
+func truncateSample(sample string) string {
+	if len(sample) <= maxSampleBytes {
+		return sample
+	}
+	end := maxSampleBytes
+	for end > 0 && !utf8.RuneStart(sample[end]) {
+		end--
+	}
+	return sample[:end] + " …"
+}
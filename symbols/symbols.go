@@ -0,0 +1,496 @@
+package symbols
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codecopy/helpers"
+)
+
+// module holds the parsed and type-checked packages of a local Go module.
+type module struct {
+	root     string
+	path     string
+	fset     *token.FileSet
+	packages map[string]*modulePackage
+	checked  map[string]*types.Package
+}
+
+// modulePackage is a single package directory within the module.
+type modulePackage struct {
+	importPath string
+	name       string
+	files      []*ast.File
+	info       *types.Info
+	types      *types.Package
+}
+
+// declaration is a top-level declaration that can be emitted as a snippet.
+// A spec taken out of a grouped type or var declaration records the keyword
+// of its group and where the spec starts after its doc comment, so it can be
+// emitted as a declaration of its own.
+type declaration struct {
+	file    string
+	pos     token.Pos
+	end     token.Pos
+	keyword string
+	spec    token.Pos
+}
+
+// Extract resolves each symbol spec (pkg.Func, pkg.Type or pkg.Type.Method)
+// within the Go module containing rootDir and returns, per file, the source of
+// the requested declarations together with the module-local types, constants,
+// variables and functions they reference. Only local sources are read; imports
+// from outside the module are not resolved, and declarations in files outside
+// rootDir, which the module may extend beyond, are left out.
+func Extract(rootDir string, specs []string) (map[string]string, []string, error) {
+	rootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	mod, err := loadModule(rootDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := mod.indexDeclarations()
+
+	var queue []types.Object
+	for _, spec := range specs {
+		obj, err := mod.lookup(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		decl, ok := index.objects[obj]
+		if !ok {
+			return nil, nil, fmt.Errorf("symbol %q has no declaration in module %s", spec, mod.path)
+		}
+		if !helpers.IsWithin(rootDir, decl.file) {
+			return nil, nil, fmt.Errorf("symbol %q is declared in %s, outside %s", spec, decl.file, rootDir)
+		}
+		queue = append(queue, obj)
+	}
+
+	selected := make(map[types.Object]bool)
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		if selected[obj] {
+			continue
+		}
+		decl, ok := index.objects[obj]
+		if !ok {
+			continue
+		}
+		selected[obj] = true
+
+		for _, dep := range index.uses[decl.node] {
+			if !selected[dep] {
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	return mod.render(rootDir, index, selected)
+}
+
+// loadModule finds the go.mod governing rootDir and parses every package in it.
+func loadModule(rootDir string) (*module, error) {
+	modRoot, modPath, err := findModule(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	mod := &module{
+		root:     modRoot,
+		path:     modPath,
+		fset:     token.NewFileSet(),
+		packages: make(map[string]*modulePackage),
+		checked:  make(map[string]*types.Package),
+	}
+
+	err = filepath.Walk(modRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if p != modRoot {
+			if helpers.IsIgnoredDir(name) || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir // nested module
+			}
+		}
+		return mod.parseDir(p)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse module %s: %v", modPath, err)
+	}
+
+	return mod, nil
+}
+
+// findModule walks up from dir to the nearest go.mod and returns its directory
+// and module path.
+func findModule(dir string) (string, string, error) {
+	for {
+		file, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) >= 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], `"`), nil
+				}
+			}
+			return "", "", fmt.Errorf("no module directive in %s", filepath.Join(dir, "go.mod"))
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("no go.mod found; symbol extraction requires a Go module")
+		}
+		dir = parent
+	}
+}
+
+// parseDir parses the non-test Go files in dir that match the current build context.
+func (m *module) parseDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(m.root, dir)
+	if err != nil {
+		return err
+	}
+	importPath := m.path
+	if rel != "." {
+		importPath = path.Join(m.path, filepath.ToSlash(rel))
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+
+		file, err := parser.ParseFile(m.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+
+		pkg, ok := m.packages[importPath]
+		if !ok {
+			pkg = &modulePackage{importPath: importPath, name: file.Name.Name}
+			m.packages[importPath] = pkg
+		}
+		if file.Name.Name == pkg.name {
+			pkg.files = append(pkg.files, file)
+		}
+	}
+
+	return nil
+}
+
+// Import implements types.Importer. Packages inside the module are
+// type-checked from source; anything else is replaced by an empty package so
+// that checking stays local and never touches the network.
+func (m *module) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := m.checked[importPath]; ok {
+		return pkg, nil
+	}
+	if local, ok := m.packages[importPath]; ok {
+		return m.check(local), nil
+	}
+
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	m.checked[importPath] = pkg
+	return pkg, nil
+}
+
+// check type-checks a module package, tolerating errors caused by unresolved
+// external imports.
+func (m *module) check(pkg *modulePackage) *types.Package {
+	if pkg.types != nil {
+		return pkg.types
+	}
+	if pkg.info != nil {
+		// Already being checked further up an (invalid) import cycle.
+		return types.NewPackage(pkg.importPath, pkg.name)
+	}
+
+	pkg.info = &types.Info{
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer: m,
+		Error:    func(error) {},
+	}
+	pkg.types, _ = conf.Check(pkg.importPath, m.fset, pkg.files, pkg.info)
+	m.checked[pkg.importPath] = pkg.types
+	return pkg.types
+}
+
+// lookup resolves a symbol spec to its object. The package part may be the
+// package name, its import path, or a trailing part of its import path.
+func (m *module) lookup(spec string) (types.Object, error) {
+	parts := strings.Split(spec, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid symbol %q: expected pkg.Name or pkg.Type.Method", spec)
+	}
+
+	// Import paths may themselves contain dots, so try the longest package
+	// prefix first.
+	for split := len(parts) - 1; split >= 1; split-- {
+		pkgRef := strings.Join(parts[:split], ".")
+		names := parts[split:]
+		if len(names) > 2 {
+			continue
+		}
+
+		for _, pkg := range m.matchPackages(pkgRef) {
+			typesPkg := m.check(pkg)
+			obj := typesPkg.Scope().Lookup(names[0])
+			if obj == nil {
+				continue
+			}
+			if len(names) == 1 {
+				return obj, nil
+			}
+
+			method, _, _ := types.LookupFieldOrMethod(obj.Type(), true, typesPkg, names[1])
+			if fn, ok := method.(*types.Func); ok {
+				return fn, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("symbol %q not found in module %s", spec, m.path)
+}
+
+// matchPackages returns the module packages referred to by pkgRef, sorted by import path.
+func (m *module) matchPackages(pkgRef string) []*modulePackage {
+	var matches []*modulePackage
+	for importPath, pkg := range m.packages {
+		if pkg.name == pkgRef || importPath == pkgRef || strings.HasSuffix(importPath, "/"+pkgRef) {
+			matches = append(matches, pkg)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].importPath < matches[j].importPath })
+	return matches
+}
+
+// declarationIndex maps module objects to the declarations that define them
+// and each declaration to the module objects it references.
+type declarationIndex struct {
+	objects map[types.Object]*indexedDecl
+	uses    map[ast.Node][]types.Object
+}
+
+type indexedDecl struct {
+	node ast.Node
+	declaration
+}
+
+// indexDeclarations records every top-level declaration of every module package.
+func (m *module) indexDeclarations() *declarationIndex {
+	index := &declarationIndex{
+		objects: make(map[types.Object]*indexedDecl),
+		uses:    make(map[ast.Node][]types.Object),
+	}
+
+	importPaths := make([]string, 0, len(m.packages))
+	for importPath := range m.packages {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+
+	for _, importPath := range importPaths {
+		pkg := m.packages[importPath]
+		m.check(pkg)
+
+		for _, file := range pkg.files {
+			fileName := m.fset.Position(file.Pos()).Filename
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					m.addDecl(index, pkg, fileName, decl, decl.Doc, []*ast.Ident{decl.Name})
+				case *ast.GenDecl:
+					if decl.Tok == token.IMPORT {
+						continue
+					}
+					// Grouped constants may depend on iota and implicit
+					// repetition, so they are always emitted as a whole.
+					if decl.Tok == token.CONST && decl.Lparen.IsValid() {
+						m.addDecl(index, pkg, fileName, decl, decl.Doc, specNames(decl.Specs))
+						continue
+					}
+					for _, spec := range decl.Specs {
+						if !decl.Lparen.IsValid() {
+							m.addDecl(index, pkg, fileName, decl, decl.Doc, specNames([]ast.Spec{spec}))
+							continue
+						}
+						entry := m.addDecl(index, pkg, fileName, spec, specDoc(spec), specNames([]ast.Spec{spec}))
+						entry.keyword, entry.spec = decl.Tok.String(), spec.Pos()
+					}
+				}
+			}
+		}
+	}
+
+	return index
+}
+
+// addDecl indexes a declaration node defining the given names and returns its entry.
+func (m *module) addDecl(index *declarationIndex, pkg *modulePackage, fileName string, node ast.Node, doc *ast.CommentGroup, names []*ast.Ident) *indexedDecl {
+	start := node.Pos()
+	if doc != nil {
+		start = doc.Pos()
+	}
+	entry := &indexedDecl{node: node, declaration: declaration{file: fileName, pos: start, end: node.End()}}
+
+	for _, name := range names {
+		if obj := pkg.info.Defs[name]; obj != nil {
+			index.objects[obj] = entry
+		}
+	}
+
+	seen := make(map[types.Object]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := pkg.info.Uses[ident]
+		if obj == nil || seen[obj] || obj.Pkg() == nil {
+			return true
+		}
+		if _, local := m.packages[obj.Pkg().Path()]; !local {
+			return true
+		}
+		seen[obj] = true
+		index.uses[node] = append(index.uses[node], obj)
+		return true
+	})
+	return entry
+}
+
+// render reads the selected declarations in files under rootDir back from
+// their source files and groups them per file in source order.
+func (m *module) render(rootDir string, index *declarationIndex, selected map[types.Object]bool) (map[string]string, []string, error) {
+	byFile := make(map[string][]declaration)
+	seen := make(map[declaration]bool)
+	for obj := range selected {
+		decl := index.objects[obj].declaration
+		if seen[decl] || !helpers.IsWithin(rootDir, decl.file) {
+			continue
+		}
+		seen[decl] = true
+		byFile[decl.file] = append(byFile[decl.file], decl)
+	}
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	snippets := make(map[string]string)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read file %s: %v", file, err)
+		}
+
+		decls := byFile[file]
+		sort.Slice(decls, func(i, j int) bool { return decls[i].pos < decls[j].pos })
+
+		tokenFile := m.fset.File(decls[0].pos)
+		var snippet strings.Builder
+		for i, decl := range decls {
+			if i > 0 {
+				snippet.WriteString("\n\n")
+			}
+			if decl.keyword == "" {
+				snippet.Write(src[tokenFile.Offset(decl.pos):tokenFile.Offset(decl.end)])
+				continue
+			}
+			snippet.Write(ungroup(src, tokenFile, decl))
+		}
+		snippet.WriteString("\n")
+		snippets[file] = snippet.String()
+	}
+
+	return snippets, files, nil
+}
+
+// ungroup returns the source of a spec taken out of a grouped declaration as
+// a declaration of its own: the spec is prefixed with its group's keyword and
+// reformatted to drop the group's indentation.
+func ungroup(src []byte, tokenFile *token.File, decl declaration) []byte {
+	var out bytes.Buffer
+	doc := src[tokenFile.Offset(decl.pos):tokenFile.Offset(decl.spec)]
+	out.Write(bytes.TrimRight(doc, " \t"))
+	out.WriteString(decl.keyword + " ")
+	out.Write(src[tokenFile.Offset(decl.spec):tokenFile.Offset(decl.end)])
+	if formatted, err := format.Source(out.Bytes()); err == nil {
+		return bytes.TrimRight(formatted, "\n")
+	}
+	return out.Bytes()
+}
+
+// specNames returns the identifiers declared by the given specs, including
+// the methods of interface types, which have no declaration of their own.
+func specNames(specs []ast.Spec) []*ast.Ident {
+	var names []*ast.Ident
+	for _, spec := range specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, spec.Name)
+			if iface, ok := spec.Type.(*ast.InterfaceType); ok {
+				for _, method := range iface.Methods.List {
+					names = append(names, method.Names...)
+				}
+			}
+		case *ast.ValueSpec:
+			names = append(names, spec.Names...)
+		}
+	}
+	return names
+}
+
+// specDoc returns the doc comment attached to a spec inside a grouped declaration.
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return spec.Doc
+	case *ast.ValueSpec:
+		return spec.Doc
+	}
+	return nil
+}
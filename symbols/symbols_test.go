@@ -0,0 +1,118 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModule creates a module with an app package that uses a lib package
+// beside it, and returns the module root.
+func writeModule(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":     "module example.com/m\n\ngo 1.22\n",
+		"lib/lib.go": "package lib\n\n// Helper helps.\nfunc Helper() int { return 1 }\n",
+		"app/app.go": `package app
+
+import "example.com/m/lib"
+
+// Store saves values.
+type Store interface {
+	// Save saves v.
+	Save(v Value) error
+}
+
+// Value is a stored value.
+type Value struct{ N int }
+
+// Run runs.
+func Run() int { return lib.Helper() }
+`,
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestExtract(t *testing.T) {
+	root := writeModule(t)
+	app := filepath.Join(root, "app")
+
+	tests := []struct {
+		name     string
+		rootDir  string
+		spec     string
+		files    []string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "dependencies across packages",
+			rootDir:  root,
+			spec:     "app.Run",
+			files:    []string{"app/app.go", "lib/lib.go"},
+			contains: []string{"func Run()", "func Helper()"},
+			excludes: []string{"type Store"},
+		},
+		{
+			name:     "dependencies outside the root are left out",
+			rootDir:  app,
+			spec:     "app.Run",
+			files:    []string{"app/app.go"},
+			contains: []string{"func Run()"},
+			excludes: []string{"func Helper()"},
+		},
+		{
+			name:     "interface method",
+			rootDir:  root,
+			spec:     "app.Store.Save",
+			files:    []string{"app/app.go"},
+			contains: []string{"type Store interface", "// Save saves v.", "type Value struct"},
+			excludes: []string{"func Run()"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, files, err := Extract(tt.rootDir, []string{tt.spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			var all strings.Builder
+			for _, file := range files {
+				rel, err := filepath.Rel(root, file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+				all.WriteString(snippets[file])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("files = %v, want %v", got, tt.files)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(all.String(), want) {
+					t.Errorf("snippets are missing %q:\n%s", want, all.String())
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(all.String(), unwanted) {
+					t.Errorf("snippets contain %q:\n%s", unwanted, all.String())
+				}
+			}
+		})
+	}
+
+	if _, _, err := Extract(app, []string{"lib.Helper"}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Extract of a symbol outside the root = %v, want an error", err)
+	}
+}
@@ -2,16 +2,21 @@ package ui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"codecopy/bundle"
 	"codecopy/constants"
+	"codecopy/languages"
+	"codecopy/redact"
 	"github.com/fatih/color"
 )
 
-// DisplayProjectInfo displays the project information, including the detected project type,
+// DisplayProjectInfo displays the project information, including the detected projects,
 // selected files, and token counts for each file.
-func DisplayProjectInfo(projectType string, selectedFiles []string, fileTokenCounts map[string]int) {
-	color.New(color.FgGreen, color.Bold).Printf("🚀 Detected project type: %s\n", projectType)
+func DisplayProjectInfo(projects string, selectedFiles []string, fileTokenCounts map[string]int) {
+	color.New(color.FgGreen, color.Bold).Printf("🚀 Detected projects: %s\n", projects)
 
 	color.New(color.FgBlue).Println("📂 Selected files:")
 	for _, file := range selectedFiles {
@@ -21,12 +26,197 @@ func DisplayProjectInfo(projectType string, selectedFiles []string, fileTokenCou
 	fmt.Println()
 }
 
+// DisplaySkippedFiles lists the selected files that were left out of the code context and why.
+func DisplaySkippedFiles(skippedFiles []bundle.Skipped) {
+	if len(skippedFiles) == 0 {
+		return
+	}
+
+	color.New(color.FgYellow).Println("⏭️  Skipped files:")
+	for _, file := range skippedFiles {
+		color.New(color.FgYellow).Printf("   %s (%s)\n", file.Path, file.Reason)
+	}
+	fmt.Println()
+}
+
+// DisplayDeniedFiles warns about sensitive files that were refused.
+func DisplayDeniedFiles(deniedFiles []string) {
+	if len(deniedFiles) == 0 {
+		return
+	}
+
+	color.New(color.FgRed, color.Bold).Println("⛔ Refused sensitive files (use --allow-sensitive to include them):")
+	for _, file := range deniedFiles {
+		color.New(color.FgRed).Printf("   %s\n", file)
+	}
+	fmt.Println()
+}
+
+// DisplayDroppedFiles lists files of a saved selection that no longer exist.
+func DisplayDroppedFiles(droppedFiles []string) {
+	if len(droppedFiles) == 0 {
+		return
+	}
+
+	color.New(color.FgYellow, color.Bold).Println("🗑️  Dropped files that no longer exist from the saved selection:")
+	for _, file := range droppedFiles {
+		color.New(color.FgYellow).Printf("   %s\n", file)
+	}
+	fmt.Println()
+}
+
+// SelectionSummary describes a saved selection and what it costs now.
+type SelectionSummary struct {
+	Name    string
+	Files   int
+	Missing int
+	Tokens  int
+	Saved   time.Time
+}
+
+// DisplaySelections lists saved selections with their current file and token counts.
+func DisplaySelections(summaries []SelectionSummary) {
+	if len(summaries) == 0 {
+		color.New(color.FgYellow).Println("No selections are saved for this repository.")
+		return
+	}
+
+	color.New(color.FgGreen, color.Bold).Println("💾 Saved selections:")
+	for _, summary := range summaries {
+		missing := ""
+		if summary.Missing > 0 {
+			missing = fmt.Sprintf(", %d missing", summary.Missing)
+		}
+		color.New(color.FgCyan).Printf("   %-20s %3d files%s · %d tokens · saved %s\n",
+			summary.Name, summary.Files, missing, summary.Tokens, summary.Saved.Format("2006-01-02 15:04"))
+	}
+}
+
+// TokenChange is a file whose token count changed between two watch runs.
+type TokenChange struct {
+	Path    string
+	Before  int
+	After   int
+	Added   bool
+	Removed bool
+}
+
+// maxWatchChanges is the number of changed files listed in a watch update.
+const maxWatchChanges = 4
+
+// DisplayWatching announces that watch mode is running.
+func DisplayWatching(files, totalTokens int, destination string) {
+	color.New(color.FgGreen, color.Bold).Printf("👀 Watching %d files (%d tokens) → %s\n", files, totalTokens, destination)
+	color.New(color.FgYellow).Println("Press Ctrl-C to stop.")
+}
+
+// DisplayWatchUpdate prints a one-line summary of a regenerated code context:
+// the new total, its change and the files that changed the most.
+func DisplayWatchUpdate(before, after int, changes []TokenChange, destination string) {
+	totalColor := color.New(color.FgCyan)
+	if after > constants.TokenLimit {
+		totalColor = color.New(color.FgRed, color.Bold)
+	}
+
+	sorted := append([]TokenChange{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return abs(sorted[i].After-sorted[i].Before) > abs(sorted[j].After-sorted[j].Before)
+	})
+	var parts []string
+	for i, change := range sorted {
+		if i == maxWatchChanges {
+			parts = append(parts, fmt.Sprintf("%d more", len(sorted)-i))
+			break
+		}
+		label := change.Path
+		switch {
+		case change.Added:
+			label += " (new)"
+		case change.Removed:
+			label += " (removed)"
+		}
+		parts = append(parts, fmt.Sprintf("%s %+d", label, change.After-change.Before))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "content changed")
+	}
+
+	fmt.Printf("🔄 %s  ", time.Now().Format("15:04:05"))
+	totalColor.Printf("%d → %d tokens (%+d)", before, after, after-before)
+	fmt.Printf("  %s → %s\n", strings.Join(parts, ", "), destination)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// DisplayServing announces the address of the HTTP API and, when it was
+// generated, the access token clients must send.
+func DisplayServing(url, token string, generated bool) {
+	color.New(color.FgGreen, color.Bold).Printf("🌐 Serving the code context API on %s\n", url)
+	if generated {
+		color.New(color.FgYellow).Printf("Access token: %s\n", token)
+	}
+	color.New(color.FgYellow).Println("Send it as \"Authorization: Bearer <token>\". Press Ctrl-C to stop.")
+}
+
+// DisplayRedactions reports the secrets that were redacted from each file.
+func DisplayRedactions(redactions map[string][]redact.Finding) {
+	if len(redactions) == 0 {
+		return
+	}
+
+	files := make([]string, 0, len(redactions))
+	for file := range redactions {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	color.New(color.FgMagenta).Println("🔒 Redacted secrets:")
+	for _, file := range files {
+		for _, finding := range redactions[file] {
+			color.New(color.FgMagenta).Printf("   %s:%d %s\n", file, finding.Line, finding.Rule)
+		}
+	}
+	fmt.Println()
+}
+
+// DisplayDiagnostics summarizes the problems with selected files, such as
+// files that could not be read.
+func DisplayDiagnostics(diagnostics bundle.Diagnostics) {
+	if len(diagnostics) == 0 {
+		return
+	}
+
+	fmt.Println()
+	color.New(color.FgRed, color.Bold).Println("⚠️  File problems (use --strict to refuse to copy):")
+	for _, problem := range diagnostics {
+		color.New(color.FgRed).Printf("   %s: %v (%s)\n", problem.Path, problem.Err, problem.Kind)
+	}
+}
+
 // DisplayTokenWarning prints a warning message when the token count exceeds the limit.
 func DisplayTokenWarning(totalTokens int) {
 	color.New(color.FgYellow).Printf("⚠️ Warning: The total token count (%d) exceeds the limit of %d tokens.\n", totalTokens, constants.TokenLimit)
 	color.New(color.FgYellow).Println("Consider reducing the number of files or their contents.")
 }
 
+// DisplayFitted reports how an over-budget selection was fitted to the budget
+// without asking.
+func DisplayFitted(outlined, dropped []string, totalTokens, budget int) {
+	color.New(color.FgYellow, color.Bold).Printf("✂️  Fitted the selection to %d tokens (budget %d):\n", totalTokens, budget)
+	for _, file := range outlined {
+		color.New(color.FgYellow).Printf("   📑 %s (outline)\n", file)
+	}
+	for _, file := range dropped {
+		color.New(color.FgYellow).Printf("   🗑️  %s (dropped)\n", file)
+	}
+	fmt.Println()
+}
+
 // DisplayProjectType prints the detected project type with color and formatting.
 
 // DisplayTreeWithTokenCounts displays the project directory tree with token counts for each file.
@@ -67,19 +257,60 @@ func DisplayHelpInfo() {
 }
 
 // DisplayHelp displays the help information for the codecopy command.
-func DisplayHelp() {
+// Language flags are listed from the registry.
+func DisplayHelp(registry *languages.Registry) {
 	color.New(color.FgGreen, color.Bold).Println("codecopy - Copy code context to clipboard")
 	color.New(color.FgYellow).Println("\nUsage:")
 	color.New(color.FgCyan).Println("  codecopy [options]")
+	color.New(color.FgCyan).Println("  codecopy watch [options]  Copy again whenever an included file changes")
+	color.New(color.FgCyan).Println("  codecopy serve [--port N] [--token T]  Serve files, trees and bundles over HTTP on localhost")
+	color.New(color.FgCyan).Println("  codecopy mcp         Run a Model Context Protocol server over stdio for agents")
+	color.New(color.FgCyan).Println("  codecopy sets        List the saved selections and their current token counts")
 	color.New(color.FgYellow).Println("\nOptions:")
-	color.New(color.FgCyan).Println("  -m    Enable manual file selection mode")
-	color.New(color.FgCyan).Println("  -py   Generate code context for Python projects")
-	color.New(color.FgCyan).Println("  -rs   Generate code context for Rust projects")
-	color.New(color.FgCyan).Println("  -go   Generate code context for Go projects")
-	color.New(color.FgCyan).Println("  -js   Generate code context for JavaScript projects")
-	color.New(color.FgCyan).Println("  -php  Generate code context for PHP projects")
-	color.New(color.FgCyan).Println("  -java Generate code context for Java projects")
-	color.New(color.FgCyan).Println("  -rb   Generate code context for Ruby projects")
-	color.New(color.FgCyan).Println("  -cs   Generate code context for C# projects")
+	color.New(color.FgCyan).Println("  -m    Pick files in a full-screen tree with live token counts")
+	color.New(color.FgCyan).Println("  --files PATH[,PATH]  Copy the given files and directories")
+	color.New(color.FgCyan).Println("  --output FILE        Write the code context to FILE instead of the clipboard")
+	color.New(color.FgCyan).Println("  --stdin              Copy the files and directories listed on standard input, one per line")
+	color.New(color.FgCyan).Println("  --symbol SPEC        Copy a Go declaration (pkg.Func, pkg.Type or pkg.Type.Method) and the module code it references")
+	color.New(color.FgCyan).Println("  --grep PATTERN       Select files whose contents match PATTERN (a regex, or a whole-word identifier)")
+	color.New(color.FgCyan).Println("  --grep-context N     With --grep, include only N lines around each match, with line numbers")
+	color.New(color.FgCyan).Println("  --binary-placeholders  List binary files with their size and type instead of skipping them")
+	color.New(color.FgCyan).Println("  --max-bytes N        Truncate files larger than N bytes to their head and tail")
+	color.New(color.FgCyan).Println("  --max-lines N        Truncate files longer than N lines to their head and tail")
+	color.New(color.FgCyan).Println("  --max-file-tokens N  Truncate files with more than N tokens to their head and tail")
+	color.New(color.FgCyan).Println("  --max-file-size N    Skip files over N bytes unless truncated or summarized (default 10 MB, 0 for no limit)")
+	color.New(color.FgCyan).Println("  --notebook-outputs N Keep up to N lines of each Jupyter notebook cell output (dropped by default)")
+	color.New(color.FgCyan).Println("  --no-redact          Copy content verbatim without redacting detected secrets")
+	color.New(color.FgCyan).Println("  --redact-strict      Refuse to copy anything if a secret is detected")
+	color.New(color.FgCyan).Println("  --strict             Refuse to copy anything if a selected file cannot be read or counted")
+	color.New(color.FgCyan).Println("  --allow-sensitive    Include key files, credential stores and other paths on the deny-list")
+	color.New(color.FgCyan).Println("  --include-generated  Include generated files, minified bundles and mocks")
+	color.New(color.FgCyan).Println("  --generated-outline  Include generated files as outlines of their declarations only")
+	color.New(color.FgCyan).Printf("  --summarize-over N   Summarize JSON, YAML, CSV and lockfiles larger than N tokens (default %d)\n", constants.SummarizeThreshold)
+	color.New(color.FgCyan).Println("  --no-summarize       Copy large data files and lockfiles in full")
+	color.New(color.FgCyan).Println("  --keep-headers       Keep license headers repeated across files instead of copying them once")
+	color.New(color.FgCyan).Println("  --minify             Strip comments, collapse blank lines and reduce indentation to save tokens")
+	color.New(color.FgCyan).Println("  --keep-license       With --minify, keep license headers")
+	color.New(color.FgCyan).Println("  --keep-doc-comments  With --minify, keep doc comments")
+	color.New(color.FgCyan).Println("  --keep-inline-comments  With --minify, keep other comments")
+	color.New(color.FgCyan).Println("  --non-interactive    Never prompt; implied when standard input is not a terminal")
+	color.New(color.FgCyan).Println("  --over-budget POLICY Over the token limit, pick files again, fail, or fit (outline, then drop the largest files); default: pick interactively, fail otherwise")
+	color.New(color.FgCyan).Println("  --last               Copy the same files with the same flags as the previous run, except --output and safety overrides")
+	color.New(color.FgCyan).Println("  --set NAME           Copy the saved set NAME, or save this run's selection as NAME")
+	for _, language := range registry.Languages() {
+		if len(language.Flags) == 0 {
+			continue
+		}
+		color.New(color.FgCyan).Printf("  %-5s Generate code context for %s projects\n", strings.Join(language.Flags, ", "), language.Name)
+	}
 	color.New(color.FgCyan).Println("  --help Display this help message")
+	color.New(color.FgYellow).Println("\nExit codes:")
+	color.New(color.FgCyan).Printf("  %d  Over the token limit with the fail policy; nothing is copied\n", constants.ExitOverBudget)
+	color.New(color.FgCyan).Printf("  %d  Selected files could not be read or counted with --strict, or in non-interactive mode\n", constants.ExitUnreadable)
+	color.New(color.FgCyan).Printf("  %d  Secrets were found with --redact-strict, or redacted in non-interactive mode\n", constants.ExitRedacted)
+	color.New(color.FgCyan).Printf("  %d  Non-interactive: the clipboard failed and the context went to code_context.txt\n", constants.ExitClipboard)
+	color.New(color.FgYellow).Println("\nConfiguration:")
+	color.New(color.FgCyan).Println("  Languages can be added or overridden in .codecopy.json or the user config file codecopy/config.json")
+	color.New(color.FgCyan).Printf("  serve listens on port %d by default; its access token is --token, CODECOPY_TOKEN or generated at start\n", constants.ServePort)
+	color.New(color.FgCyan).Println("  Extra redaction rules and denied paths can be set there under \"redact\" and \"deny\"")
 }